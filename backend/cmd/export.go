@@ -0,0 +1,132 @@
+package main
+
+import (
+	"backend/internal/entity"
+	"backend/internal/service"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxExportBodyBytes bounds the JSON body of an export POST.
+const maxExportBodyBytes = 1 << 20
+
+// vcdRequest is the body of POST /vcd. GET /vcd takes the same fields as query
+// parameters, with vectors encoded as JSON.
+type vcdRequest struct {
+	CircuitID string                     `json:"circuitID"`
+	Vectors   [][]*entity.InputNodeValue `json:"vectors"`
+}
+
+// vcdHandler serves the Value Change Dump of a circuit evaluated for a sequence of
+// input vectors as a file download.
+func vcdHandler(circuitService service.CircuitService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req vcdRequest
+		if !decodeExportRequest(w, r, &req, &req.CircuitID, map[string]interface{}{"vectors": &req.Vectors}) {
+			return
+		}
+
+		serveExport(w, r, circuitService, req.CircuitID, ".vcd", func(circuit *entity.Circuit, out io.Writer) error {
+			return circuitService.ExportVCD(r.Context(), circuit, req.Vectors, out)
+		})
+	})
+}
+
+// dimacsRequest is the body of POST /dimacs. GET /dimacs takes the same fields as
+// query parameters, with assertOutputs encoded as JSON.
+type dimacsRequest struct {
+	CircuitID     string                   `json:"circuitID"`
+	AssertOutputs []*entity.ExpectedOutput `json:"assertOutputs"`
+}
+
+// dimacsHandler serves the DIMACS CNF encoding of a circuit as a file download.
+func dimacsHandler(circuitService service.CircuitService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req dimacsRequest
+		if !decodeExportRequest(w, r, &req, &req.CircuitID, map[string]interface{}{"assertOutputs": &req.AssertOutputs}) {
+			return
+		}
+
+		serveExport(w, r, circuitService, req.CircuitID, ".cnf", func(circuit *entity.Circuit, out io.Writer) error {
+			return circuitService.ExportDIMACS(r.Context(), circuit, req.AssertOutputs, out)
+		})
+	})
+}
+
+// decodeExportRequest reads an export request into req: from the JSON body of a POST,
+// or from the query parameters of a GET, where circuitID is plain text and the
+// parameters in jsonParams are JSON. It responds with an error and returns false if
+// the request is invalid.
+func decodeExportRequest(w http.ResponseWriter, r *http.Request, req interface{}, circuitID *string, jsonParams map[string]interface{}) bool {
+	switch r.Method {
+	case http.MethodGet:
+		*circuitID = r.URL.Query().Get("circuitID")
+		for name, target := range jsonParams {
+			if value := r.URL.Query().Get(name); value != "" {
+				if err := json.Unmarshal([]byte(value), target); err != nil {
+					http.Error(w, fmt.Sprintf("invalid %s: %v", name, err), http.StatusBadRequest)
+					return false
+				}
+			}
+		}
+	case http.MethodPost:
+		body := http.MaxBytesReader(w, r.Body, maxExportBodyBytes)
+		if err := json.NewDecoder(body).Decode(req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("request body exceeds %d bytes", maxExportBodyBytes), http.StatusRequestEntityTooLarge)
+				return false
+			}
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return false
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if *circuitID == "" {
+		http.Error(w, "circuitID is required", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// serveExport renders an export of the circuit and sends it as a file download
+// named after the circuit.
+func serveExport(w http.ResponseWriter, r *http.Request, circuitService service.CircuitService, circuitID string, extension string, render func(*entity.Circuit, io.Writer) error) {
+	circuit, err := circuitService.GetCircuit(r.Context(), circuitID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get circuit: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Render fully before responding so errors are not reported after a 200 status.
+	var content bytes.Buffer
+	if err := render(circuit, &content); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(circuit, extension)))
+	w.Write(content.Bytes())
+}
+
+// exportFilename names a downloaded file after the circuit's title.
+func exportFilename(circuit *entity.Circuit, extension string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '"' || r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(circuit.Title))
+	if name == "" {
+		name = circuit.ID
+	}
+	return name + extension
+}
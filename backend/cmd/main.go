@@ -4,17 +4,24 @@ import (
 	"backend/data"
 	_ "backend/data"
 	"backend/graph"
+	"backend/internal/entity"
 	"backend/internal/service"
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 const defaultPort = "8080"
@@ -26,12 +33,14 @@ func main() {
 	}
 
 	resolver := &graph.Resolver{
-		CircuitService: service.NewCircuitService(data.SqlCircuitRepository()),
+		CircuitService: service.NewCircuitService(data.SqlCircuitRepository(), service.NewChangeHub(data.NewChangeBus())),
 	}
 	srv := createServer(resolver)
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
 	http.Handle("/query", corsMiddleware(srv))
+	http.Handle("/vcd", corsMiddleware(vcdHandler(resolver.CircuitService)))
+	http.Handle("/dimacs", corsMiddleware(dimacsHandler(resolver.CircuitService)))
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
@@ -39,9 +48,36 @@ func main() {
 
 func createServer(resolver *graph.Resolver) *handler.Server {
 	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			// Allow connections from any origin during development, like corsMiddleware
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		// Each operation gets its own loaders so batched lookups are never shared across requests.
+		// Subscriptions are long-lived, so they skip the cache and always see fresh circuits.
+		if graphql.GetOperationContext(ctx).Operation.Operation == ast.Subscription {
+			return next(ctx)
+		}
+		return next(graph.WithLoaders(ctx, resolver.CircuitService))
+	})
+	srv.SetErrorPresenter(func(ctx context.Context, err error) *gqlerror.Error {
+		gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+		// Surface stale writes with a machine-readable code so clients can refetch and retry
+		var conflict *entity.ConflictError
+		if errors.As(err, &conflict) {
+			gqlErr.Extensions = map[string]interface{}{
+				"code":           "CONFLICT",
+				"currentVersion": conflict.CurrentVersion,
+			}
+		}
+		return gqlErr
+	})
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 	srv.Use(extension.Introspection{})
 	srv.Use(extension.AutomaticPersistedQuery{
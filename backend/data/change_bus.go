@@ -0,0 +1,249 @@
+package data
+
+import (
+	"backend/internal/entity"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// changeChannel is the Postgres NOTIFY channel circuit change events are sent on.
+const changeChannel = "circuit_changes"
+
+// busSubscriberBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it.
+const busSubscriberBuffer = 256
+
+// ChangeBus distributes circuit change events to every subscriber in every backend
+// instance sharing the bus. Events published on an instance are also delivered to
+// that instance's own subscribers.
+type ChangeBus interface {
+	// Publish sends event to all subscribers of the bus.
+	Publish(ctx context.Context, event *entity.CircuitChangeEvent) error
+	// Subscribe returns a channel of all events on the bus. The channel is closed
+	// when ctx is done or the bus is closed.
+	Subscribe(ctx context.Context) <-chan *entity.CircuitChangeEvent
+	// Close stops the bus and closes all subscriber channels.
+	Close() error
+}
+
+// NewChangeBus returns the change bus configured by CHANGE_BUS: "postgres" (the
+// default) propagates events between instances with LISTEN/NOTIFY, "local" keeps
+// them in-process. If the Postgres listener cannot be started, it falls back to
+// the in-process bus.
+func NewChangeBus() ChangeBus {
+	if os.Getenv("CHANGE_BUS") == "local" {
+		return NewLocalChangeBus()
+	}
+	bus, err := NewPostgresChangeBus(dbConnStr)
+	if err != nil {
+		log.Printf("warning: falling back to in-process change bus: %v", err)
+		return NewLocalChangeBus()
+	}
+	return bus
+}
+
+// fanout delivers events to a dynamic set of subscriber channels without blocking.
+type fanout struct {
+	mu     sync.RWMutex
+	subs   map[chan *entity.CircuitChangeEvent]struct{}
+	closed bool
+}
+
+func newFanout() *fanout {
+	return &fanout{subs: make(map[chan *entity.CircuitChangeEvent]struct{})}
+}
+
+func (f *fanout) subscribe(ctx context.Context) <-chan *entity.CircuitChangeEvent {
+	ch := make(chan *entity.CircuitChangeEvent, busSubscriberBuffer)
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		close(ch)
+		return ch
+	}
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+		f.mu.Unlock()
+	}()
+
+	return ch
+}
+
+func (f *fanout) deliver(event *entity.CircuitChangeEvent) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for ch := range f.subs {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("warning: dropping %s event for slow change bus subscriber of circuit %s", event.Kind, event.CircuitID)
+		}
+	}
+}
+
+func (f *fanout) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for ch := range f.subs {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+// localChangeBus delivers events only within the current process.
+type localChangeBus struct {
+	subs *fanout
+}
+
+func NewLocalChangeBus() ChangeBus {
+	return &localChangeBus{subs: newFanout()}
+}
+
+func (b *localChangeBus) Publish(ctx context.Context, event *entity.CircuitChangeEvent) error {
+	b.subs.deliver(event)
+	return nil
+}
+
+func (b *localChangeBus) Subscribe(ctx context.Context) <-chan *entity.CircuitChangeEvent {
+	return b.subs.subscribe(ctx)
+}
+
+func (b *localChangeBus) Close() error {
+	b.subs.close()
+	return nil
+}
+
+// postgresChangeBus publishes events with pg_notify and receives them, including
+// its own, through a dedicated LISTEN connection.
+type postgresChangeBus struct {
+	listener *pq.Listener
+	subs     *fanout
+}
+
+func NewPostgresChangeBus(connStr string) (ChangeBus, error) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("warning: change bus listener event %d: %v", ev, err)
+		}
+	})
+	if err := listener.Listen(changeChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", changeChannel, err)
+	}
+
+	bus := &postgresChangeBus{listener: listener, subs: newFanout()}
+	go bus.run()
+	return bus, nil
+}
+
+func (b *postgresChangeBus) Publish(ctx context.Context, event *entity.CircuitChangeEvent) error {
+	payload, err := encodeChangeEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode change event: %w", err)
+	}
+	if _, err := DB.ExecContext(ctx, "SELECT pg_notify($1, $2)", changeChannel, payload); err != nil {
+		return fmt.Errorf("failed to notify change event: %w", err)
+	}
+	return nil
+}
+
+func (b *postgresChangeBus) Subscribe(ctx context.Context) <-chan *entity.CircuitChangeEvent {
+	return b.subs.subscribe(ctx)
+}
+
+func (b *postgresChangeBus) Close() error {
+	return b.listener.Close()
+}
+
+func (b *postgresChangeBus) run() {
+	defer b.subs.close()
+
+	for notification := range b.listener.Notify {
+		if notification == nil {
+			// The listener reconnected; anything sent while it was down is lost
+			log.Printf("warning: change bus reconnected, events may have been missed")
+			continue
+		}
+		event, err := decodeChangeEvent(notification.Extra)
+		if err != nil {
+			log.Printf("warning: ignoring malformed change event: %v", err)
+			continue
+		}
+		b.subs.deliver(event)
+	}
+}
+
+// changeMessage is the wire format of a CircuitChangeEvent in a NOTIFY payload.
+type changeMessage struct {
+	CircuitID string        `json:"circuitID"`
+	Kind      string        `json:"kind"`
+	Version   int32         `json:"version"`
+	Node      *snapshotNode `json:"node,omitempty"`
+	Edge      *snapshotEdge `json:"edge,omitempty"`
+}
+
+func encodeChangeEvent(event *entity.CircuitChangeEvent) (string, error) {
+	msg := changeMessage{CircuitID: event.CircuitID, Kind: string(event.Kind), Version: event.Version}
+	if event.Node != nil {
+		nodeID, nodeType, title, referencedCircuitID, err := circuitRepositoryImpl{}.nodeColumns(event.Node)
+		if err != nil {
+			return "", err
+		}
+		sn := newSnapshotNode(nodeID, nodeType, title, referencedCircuitID)
+		msg.Node = &sn
+	}
+	if event.Edge != nil {
+		se := newSnapshotEdge(event.Edge)
+		msg.Edge = &se
+	}
+	data, err := json.Marshal(msg)
+	return string(data), err
+}
+
+func decodeChangeEvent(payload string) (*entity.CircuitChangeEvent, error) {
+	var msg changeMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(msg.CircuitID); err != nil {
+		return nil, fmt.Errorf("invalid circuit ID %q", msg.CircuitID)
+	}
+
+	event := &entity.CircuitChangeEvent{
+		CircuitID: msg.CircuitID,
+		Kind:      entity.CircuitChangeKind(msg.Kind),
+		Version:   msg.Version,
+	}
+	if !event.Kind.IsValid() {
+		return nil, fmt.Errorf("unknown change kind %q", msg.Kind)
+	}
+	if msg.Node != nil {
+		if event.Node = msg.Node.toNode(circuitRepositoryImpl{}); event.Node == nil {
+			return nil, fmt.Errorf("unknown node type %q", msg.Node.Type)
+		}
+	}
+	if msg.Edge != nil {
+		event.Edge = msg.Edge.toEdge()
+	}
+	return event, nil
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"backend/internal/entity"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNothingToUndo and ErrNothingToRedo are returned when the operation log has
+// no entry in the requested direction.
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+func (c circuitRepositoryImpl) UndoOperation(ctx context.Context, circuitID string, expectedVersion *int) (*entity.CircuitOperation, error) {
+	// The most recent operation that is still applied
+	return c.replayOperation(ctx, circuitID, expectedVersion,
+		"SELECT id, kind, payload FROM circuit_operations WHERE circuit_id = $1 AND NOT undone ORDER BY id DESC LIMIT 1 FOR UPDATE",
+		true, ErrNothingToUndo,
+	)
+}
+
+func (c circuitRepositoryImpl) RedoOperation(ctx context.Context, circuitID string, expectedVersion *int) (*entity.CircuitOperation, error) {
+	// The earliest operation that has been undone
+	return c.replayOperation(ctx, circuitID, expectedVersion,
+		"SELECT id, kind, payload FROM circuit_operations WHERE circuit_id = $1 AND undone ORDER BY id ASC LIMIT 1 FOR UPDATE",
+		false, ErrNothingToRedo,
+	)
+}
+
+// replayOperation picks one logged operation with query and applies it (undo=false)
+// or its inverse (undo=true) in a single transaction, bumping the circuit's version
+// and recording a revision like any other edit.
+func (c circuitRepositoryImpl) replayOperation(ctx context.Context, circuitID string, expectedVersion *int, query string, undo bool, errEmpty error) (*entity.CircuitOperation, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	version, err := c.bumpVersion(ctx, tx, circuitID, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &entity.CircuitOperation{CircuitID: circuitID, Version: int32(version)}
+	var kind string
+	var payload []byte
+	err = tx.QueryRowContext(ctx, query, circuitID).Scan(&op.ID, &kind, &payload)
+	if err == sql.ErrNoRows {
+		return nil, errEmpty
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query operation log for circuit %s: %w", circuitID, err)
+	}
+	op.Kind = entity.OperationKind(kind)
+	if err := c.decodeOperation(op, payload); err != nil {
+		return nil, fmt.Errorf("failed to decode operation %d: %w", op.ID, err)
+	}
+
+	switch {
+	case op.Kind == entity.OperationAddNode && undo:
+		err = c.deleteRow(ctx, tx, "nodes", circuitID, op.Node.GetID())
+	case op.Kind == entity.OperationAddNode:
+		err = c.insertNode(ctx, tx, circuitID, op.Node)
+	case op.Kind == entity.OperationAddEdge && undo:
+		err = c.deleteRow(ctx, tx, "edges", circuitID, op.Edge.ID)
+	case op.Kind == entity.OperationAddEdge:
+		err = c.insertEdge(ctx, tx, circuitID, op.Edge)
+	default:
+		err = fmt.Errorf("unknown operation kind: %s", op.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE circuit_operations SET undone = $1 WHERE id = $2", undo, op.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update operation %d: %w", op.ID, err)
+	}
+
+	if err := c.recordRevision(ctx, tx, circuitID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// logOperation appends an operation to the circuit's log. A new edit discards
+// everything that was undone before it, so redo only ever follows undo.
+func (c circuitRepositoryImpl) logOperation(ctx context.Context, tx *sql.Tx, circuitID string, kind entity.OperationKind, subject interface{}) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM circuit_operations WHERE circuit_id = $1 AND undone", circuitID)
+	if err != nil {
+		return fmt.Errorf("failed to clear redo history for circuit %s: %w", circuitID, err)
+	}
+
+	var payload []byte
+	switch s := subject.(type) {
+	case entity.Node:
+		nodeID, nodeType, title, referencedCircuitID, err := c.nodeColumns(s)
+		if err != nil {
+			return err
+		}
+		payload, err = json.Marshal(newSnapshotNode(nodeID, nodeType, title, referencedCircuitID))
+		if err != nil {
+			return fmt.Errorf("failed to encode operation payload: %w", err)
+		}
+	case *entity.Edge:
+		payload, err = json.Marshal(newSnapshotEdge(s))
+		if err != nil {
+			return fmt.Errorf("failed to encode operation payload: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported operation subject: %T", subject)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO circuit_operations (circuit_id, kind, payload) VALUES ($1, $2, $3)",
+		circuitID, string(kind), payload,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to log operation for circuit %s: %w", circuitID, err)
+	}
+	return nil
+}
+
+func (c circuitRepositoryImpl) decodeOperation(op *entity.CircuitOperation, payload []byte) error {
+	switch op.Kind {
+	case entity.OperationAddNode:
+		var sn snapshotNode
+		if err := json.Unmarshal(payload, &sn); err != nil {
+			return err
+		}
+		op.Node = sn.toNode(c)
+		if op.Node == nil {
+			return fmt.Errorf("unknown node type '%s'", sn.Type)
+		}
+	case entity.OperationAddEdge:
+		var se snapshotEdge
+		if err := json.Unmarshal(payload, &se); err != nil {
+			return err
+		}
+		op.Edge = se.toEdge()
+	default:
+		return fmt.Errorf("unknown operation kind: %s", op.Kind)
+	}
+	return nil
+}
+
+// deleteRow removes a single node or edge owned by the circuit, failing if it no longer exists.
+func (c circuitRepositoryImpl) deleteRow(ctx context.Context, tx *sql.Tx, table, circuitID, id string) error {
+	res, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = $1 AND circuit_id = $2", id, circuitID)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from %s: %w", id, table, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for delete of %s from %s: %w", id, table, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s no longer exists in %s of circuit %s", id, table, circuitID)
+	}
+	return nil
+}
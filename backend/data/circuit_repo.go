@@ -1,13 +1,47 @@
 package data
 
-import "backend/internal/entity"
+import (
+	"backend/internal/entity"
+	"context"
+)
 
+// CircuitRepository persists circuits. Every write bumps the circuit's version
+// (AddNode and AddEdge return the new one); writes given a non-nil expectedVersion
+// fail with *entity.ConflictError when the stored version differs.
 type CircuitRepository interface {
-	CreateCircuit(circuit *entity.Circuit) error
-	AddNode(circuitID string, node entity.Node) error
-	AddEdge(circuitID string, edge *entity.Edge) error
-	GetCircuit(id string) (*entity.Circuit, error)
-	GetAllCircuits() ([]*entity.Circuit, error)
-	UpdateCircuit(circuit *entity.Circuit) error
-	DeleteCircuit(id string) error
+	CreateCircuit(ctx context.Context, circuit *entity.Circuit) error
+	AddNode(ctx context.Context, circuitID string, node entity.Node, expectedVersion *int) (int, error)
+	AddEdge(ctx context.Context, circuitID string, edge *entity.Edge, expectedVersion *int) (int, error)
+	GetCircuit(ctx context.Context, id string) (*entity.Circuit, error)
+	GetCircuitsByIDs(ctx context.Context, ids []string) ([]*entity.Circuit, error)
+	GetAllCircuits(ctx context.Context) ([]*entity.Circuit, error)
+	// UpdateCircuit replaces the circuit's title, nodes and edges and returns the
+	// circuit as it was, read in the same transaction.
+	UpdateCircuit(ctx context.Context, circuit *entity.Circuit, expectedVersion *int) (*entity.Circuit, error)
+	DeleteCircuit(ctx context.Context, id string) error
+
+	// Revision history. Every write above records a new numbered snapshot.
+	GetCircuitRevisions(ctx context.Context, circuitID string) ([]*entity.CircuitRevision, error)
+	GetCircuitRevision(ctx context.Context, circuitID string, revision int) (*entity.Circuit, error)
+
+	// Operation log. AddNode and AddEdge are logged as invertible operations;
+	// undo reverts the latest applied one and redo re-applies the earliest undone one.
+	UndoOperation(ctx context.Context, circuitID string, expectedVersion *int) (*entity.CircuitOperation, error)
+	RedoOperation(ctx context.Context, circuitID string, expectedVersion *int) (*entity.CircuitOperation, error)
+
+	// Test suites. Test names are unique per circuit; tests and runs are deleted with
+	// their circuit. GetCircuitTestRuns returns the newest runs first.
+	AddCircuitTest(ctx context.Context, test *entity.CircuitTest) error
+	GetCircuitTests(ctx context.Context, circuitID string) ([]*entity.CircuitTest, error)
+	DeleteCircuitTest(ctx context.Context, circuitID string, testID string) error
+	AddCircuitTestRun(ctx context.Context, run *entity.CircuitTestRun) error
+	GetCircuitTestRuns(ctx context.Context, circuitID string, limit int) ([]*entity.CircuitTestRun, error)
+
+	// Exercises. Submissions are deleted with their exercise or circuit;
+	// GetExerciseSubmissions returns the newest submissions first.
+	CreateExercise(ctx context.Context, exercise *entity.Exercise) error
+	GetExercise(ctx context.Context, id string) (*entity.Exercise, error)
+	GetAllExercises(ctx context.Context) ([]*entity.Exercise, error)
+	AddExerciseSubmission(ctx context.Context, submission *entity.ExerciseSubmission) error
+	GetExerciseSubmissions(ctx context.Context, exerciseID string, limit int) ([]*entity.ExerciseSubmission, error)
 }
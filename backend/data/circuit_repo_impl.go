@@ -2,6 +2,7 @@ package data
 
 import (
 	"backend/internal/entity"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,24 +10,28 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var DB *sql.DB
 
+// dbConnStr is the connection string DB was opened with. Components that need
+// their own dedicated connection, such as the LISTEN side of the change bus, reuse it.
+var dbConnStr string
+
 func init() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("Warning: Could not load .env file. Using environment variables.")
 	}
 
-	connStr := fmt.Sprintf("user=%s dbname=%s password=%s sslmode=disable",
+	dbConnStr = fmt.Sprintf("user=%s dbname=%s password=%s sslmode=disable",
 		os.Getenv("DATABASE_USER"),
 		os.Getenv("DATABASE_NAME"),
 		os.Getenv("DATABASE_PASSWORD"),
 	)
 
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open("postgres", dbConnStr)
 	if err != nil {
 		log.Fatalf("Error opening database connection: %v", err)
 	}
@@ -44,8 +49,8 @@ func SqlCircuitRepository() CircuitRepository {
 	return &circuitRepositoryImpl{}
 }
 
-func (c circuitRepositoryImpl) CreateCircuit(circuit *entity.Circuit) error {
-	tx, err := DB.Begin()
+func (c circuitRepositoryImpl) CreateCircuit(ctx context.Context, circuit *entity.Circuit) error {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -57,91 +62,227 @@ func (c circuitRepositoryImpl) CreateCircuit(circuit *entity.Circuit) error {
 	}
 
 	// Insert circuit
-	_, err = tx.Exec("INSERT INTO circuits (id, title) VALUES ($1, $2)", circuit.ID, circuit.Title)
+	_, err = tx.ExecContext(ctx, "INSERT INTO circuits (id, title, version) VALUES ($1, $2, 1)", circuit.ID, circuit.Title)
 	if err != nil {
 		return fmt.Errorf("failed to insert circuit: %w", err)
 	}
+	circuit.Version = 1
 
 	// Insert nodes and edges
-	if err := c.upsertNodesAndEdges(tx, circuit); err != nil {
+	if err := c.upsertNodesAndEdges(ctx, tx, circuit); err != nil {
+		return err
+	}
+
+	if err := c.recordRevision(ctx, tx, circuit.ID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (c circuitRepositoryImpl) AddNode(circuitID string, node entity.Node) error {
-	tx, err := DB.Begin()
+func (c circuitRepositoryImpl) AddNode(ctx context.Context, circuitID string, node entity.Node, expectedVersion *int) (int, error) {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	version, err := c.bumpVersion(ctx, tx, circuitID, expectedVersion)
+	if err != nil {
+		return 0, err
+	}
+
 	// Insert the node
-	if err := c.insertNode(tx, circuitID, node); err != nil {
-		return err
+	if err := c.insertNode(ctx, tx, circuitID, node); err != nil {
+		return 0, err
 	}
 
-	return tx.Commit()
+	if err := c.logOperation(ctx, tx, circuitID, entity.OperationAddNode, node); err != nil {
+		return 0, err
+	}
+
+	if err := c.recordRevision(ctx, tx, circuitID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return version, nil
 }
 
-func (c circuitRepositoryImpl) AddEdge(circuitID string, edge *entity.Edge) error {
-	tx, err := DB.Begin()
+func (c circuitRepositoryImpl) AddEdge(ctx context.Context, circuitID string, edge *entity.Edge, expectedVersion *int) (int, error) {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Assign UUID to edge if it doesn't have one
-	if edge.ID == "" {
-		edge.ID = uuid.New().String()
+	version, err := c.bumpVersion(ctx, tx, circuitID, expectedVersion)
+	if err != nil {
+		return 0, err
 	}
 
 	// Insert edge
-	_, err = tx.Exec(
-		"INSERT INTO edges (id, circuit_id, source_node_id, target_node_id) VALUES ($1, $2, $3, $4)",
-		edge.ID, circuitID, edge.SourceNodeID, edge.TargetNodeID,
-	)
+	if err := c.insertEdge(ctx, tx, circuitID, edge); err != nil {
+		return 0, err
+	}
+
+	if err := c.logOperation(ctx, tx, circuitID, entity.OperationAddEdge, edge); err != nil {
+		return 0, err
+	}
+
+	if err := c.recordRevision(ctx, tx, circuitID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (c circuitRepositoryImpl) GetCircuit(ctx context.Context, id string) (*entity.Circuit, error) {
+	circuit := &entity.Circuit{ID: id}
+
+	// Fetch circuit details
+	err := DB.QueryRowContext(ctx, "SELECT title, version FROM circuits WHERE id = $1", id).Scan(&circuit.Title, &circuit.Version)
 	if err != nil {
-		return fmt.Errorf("failed to insert edge %s: %w", edge.ID, err)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("circuit with id %s not found", id)
+		}
+		return nil, fmt.Errorf("failed to query circuit %s: %w", id, err)
 	}
 
-	return tx.Commit()
+	// Fetch nodes
+	nodes, err := c.fetchNodesForCircuit(ctx, DB, id)
+	if err != nil {
+		return nil, err
+	}
+	circuit.Nodes = nodes
+
+	// Fetch edges
+	edges, err := c.fetchEdgesForCircuit(ctx, DB, id)
+	if err != nil {
+		return nil, err
+	}
+	circuit.Edges = edges
+
+	return circuit, nil
 }
 
-func (c circuitRepositoryImpl) GetCircuit(id string) (*entity.Circuit, error) {
-	// Use a map to prevent infinite recursion on circular dependencies
-	visited := make(map[string]bool)
-	return c.getCircuitRecursive(id, visited)
+func (c circuitRepositoryImpl) GetCircuitsByIDs(ctx context.Context, ids []string) ([]*entity.Circuit, error) {
+	if len(ids) == 0 {
+		return []*entity.Circuit{}, nil
+	}
+
+	// Fetch circuit details for the whole batch
+	rows, err := DB.QueryContext(ctx, "SELECT id, title, version FROM circuits WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query circuits: %w", err)
+	}
+	defer rows.Close()
+
+	circuitMap := make(map[string]*entity.Circuit)
+	var circuits []*entity.Circuit
+	for rows.Next() {
+		circuit := &entity.Circuit{Nodes: []entity.Node{}, Edges: []*entity.Edge{}}
+		if err := rows.Scan(&circuit.ID, &circuit.Title, &circuit.Version); err != nil {
+			return nil, fmt.Errorf("failed to scan circuit row: %w", err)
+		}
+		circuitMap[circuit.ID] = circuit
+		circuits = append(circuits, circuit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating circuit rows: %w", err)
+	}
+
+	// Fetch nodes for every circuit in the batch
+	nodeRows, err := DB.QueryContext(ctx,
+		"SELECT circuit_id, id, type, title, referenced_circuit_id FROM nodes WHERE circuit_id = ANY($1) ORDER BY id",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nodes: %w", err)
+	}
+	defer nodeRows.Close()
+
+	for nodeRows.Next() {
+		var circuitID, id, nodeType string
+		var title, referencedCircuitID sql.NullString
+		if err := nodeRows.Scan(&circuitID, &id, &nodeType, &title, &referencedCircuitID); err != nil {
+			return nil, fmt.Errorf("failed to scan node row: %w", err)
+		}
+		circuit, ok := circuitMap[circuitID]
+		if !ok {
+			continue
+		}
+		if node := c.createNodeFromDB(id, nodeType, title, referencedCircuitID); node != nil {
+			circuit.Nodes = append(circuit.Nodes, node)
+		}
+	}
+	if err := nodeRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating node rows: %w", err)
+	}
+
+	// Fetch edges for every circuit in the batch
+	edgeRows, err := DB.QueryContext(ctx,
+		"SELECT circuit_id, id, source_node_id, target_node_id, COALESCE(source_port, ''), COALESCE(target_port, '') FROM edges WHERE circuit_id = ANY($1) ORDER BY id",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query edges: %w", err)
+	}
+	defer edgeRows.Close()
+
+	for edgeRows.Next() {
+		var circuitID string
+		edge := &entity.Edge{}
+		if err := edgeRows.Scan(&circuitID, &edge.ID, &edge.SourceNodeID, &edge.TargetNodeID, &edge.SourcePort, &edge.TargetPort); err != nil {
+			return nil, fmt.Errorf("failed to scan edge row: %w", err)
+		}
+		if circuit, ok := circuitMap[circuitID]; ok {
+			circuit.Edges = append(circuit.Edges, edge)
+		}
+	}
+	if err := edgeRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating edge rows: %w", err)
+	}
+
+	return circuits, nil
 }
 
-func (c circuitRepositoryImpl) GetAllCircuits() ([]*entity.Circuit, error) {
+func (c circuitRepositoryImpl) GetAllCircuits(ctx context.Context) ([]*entity.Circuit, error) {
 	// Use a single query with JOINs to fetch all data at once
 	query := `
 		SELECT 
 			c.id as circuit_id,
 			c.title as circuit_title,
+			c.version as circuit_version,
 			n.id as node_id,
 			n.type as node_type,
 			n.title as node_title,
 			n.referenced_circuit_id,
 			e.id as edge_id,
 			e.source_node_id,
-			e.target_node_id
+			e.target_node_id,
+			COALESCE(e.source_port, '') as source_port,
+			COALESCE(e.target_port, '') as target_port
 		FROM circuits c
 		LEFT JOIN nodes n ON c.id = n.circuit_id
 		LEFT JOIN edges e ON c.id = e.circuit_id
 		ORDER BY c.title, n.id, e.id
 	`
 
-	rows, err := DB.Query(query)
+	rows, err := DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query circuits with joins: %w", err)
 	}
 	defer rows.Close()
 
-	// Map to store circuits by ID
+	// Map to store circuits by ID, and the order the query returned them in
 	circuitMap := make(map[string]*entity.Circuit)
+	var circuits []*entity.Circuit
 
 	// Map to store nodes by circuit ID and node ID to avoid duplicates
 	nodeMap := make(map[string]map[string]entity.Node)
@@ -151,13 +292,15 @@ func (c circuitRepositoryImpl) GetAllCircuits() ([]*entity.Circuit, error) {
 
 	for rows.Next() {
 		var circuitID, circuitTitle string
+		var circuitVersion int32
 		var nodeID, nodeType, nodeTitle, referencedCircuitID sql.NullString
 		var edgeID, sourceNodeID, targetNodeID sql.NullString
+		var sourcePort, targetPort string
 
 		err := rows.Scan(
-			&circuitID, &circuitTitle,
+			&circuitID, &circuitTitle, &circuitVersion,
 			&nodeID, &nodeType, &nodeTitle, &referencedCircuitID,
-			&edgeID, &sourceNodeID, &targetNodeID,
+			&edgeID, &sourceNodeID, &targetNodeID, &sourcePort, &targetPort,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan joined row: %w", err)
@@ -166,13 +309,15 @@ func (c circuitRepositoryImpl) GetAllCircuits() ([]*entity.Circuit, error) {
 		// Create circuit if it doesn't exist
 		if _, exists := circuitMap[circuitID]; !exists {
 			circuitMap[circuitID] = &entity.Circuit{
-				ID:    circuitID,
-				Title: circuitTitle,
-				Nodes: []entity.Node{},
-				Edges: []*entity.Edge{},
+				ID:      circuitID,
+				Title:   circuitTitle,
+				Version: circuitVersion,
+				Nodes:   []entity.Node{},
+				Edges:   []*entity.Edge{},
 			}
 			nodeMap[circuitID] = make(map[string]entity.Node)
 			edgeMap[circuitID] = []*entity.Edge{}
+			circuits = append(circuits, circuitMap[circuitID])
 		}
 
 		// Add node if it exists and hasn't been added yet
@@ -181,6 +326,7 @@ func (c circuitRepositoryImpl) GetAllCircuits() ([]*entity.Circuit, error) {
 				node := c.createNodeFromDB(nodeID.String, nodeType.String, nodeTitle, referencedCircuitID)
 				if node != nil {
 					nodeMap[circuitID][nodeID.String] = node
+					circuitMap[circuitID].Nodes = append(circuitMap[circuitID].Nodes, node)
 				}
 			}
 		}
@@ -201,6 +347,8 @@ func (c circuitRepositoryImpl) GetAllCircuits() ([]*entity.Circuit, error) {
 					ID:           edgeID.String,
 					SourceNodeID: sourceNodeID.String,
 					TargetNodeID: targetNodeID.String,
+					SourcePort:   sourcePort,
+					TargetPort:   targetPort,
 				}
 				edgeMap[circuitID] = append(edgeMap[circuitID], edge)
 			}
@@ -211,59 +359,76 @@ func (c circuitRepositoryImpl) GetAllCircuits() ([]*entity.Circuit, error) {
 		return nil, fmt.Errorf("error iterating joined rows: %w", err)
 	}
 
-	// Convert maps to slice and populate circuit data
-	var circuits []*entity.Circuit
-	for circuitID, circuit := range circuitMap {
-		// Add nodes to circuit
-		for _, node := range nodeMap[circuitID] {
-			circuit.Nodes = append(circuit.Nodes, node)
-		}
-
-		// Add edges to circuit
-		circuit.Edges = edgeMap[circuitID]
-
-		circuits = append(circuits, circuit)
+	// Add edges to circuits; nodes were added in query order as they were first seen
+	for _, circuit := range circuits {
+		circuit.Edges = edgeMap[circuit.ID]
 	}
 
 	return circuits, nil
 }
 
-func (c circuitRepositoryImpl) UpdateCircuit(circuit *entity.Circuit) error {
-	tx, err := DB.Begin()
+func (c circuitRepositoryImpl) UpdateCircuit(ctx context.Context, circuit *entity.Circuit, expectedVersion *int) (*entity.Circuit, error) {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// 1. Check if circuit exists and update its title
-	res, err := tx.Exec("UPDATE circuits SET title = $1 WHERE id = $2", circuit.Title, circuit.ID)
+	// 1. Check the circuit exists at the expected version; this locks its row, so
+	// the state read next is the one being replaced
+	version, err := c.bumpVersion(ctx, tx, circuit.ID, expectedVersion)
 	if err != nil {
-		return fmt.Errorf("failed to update circuit %s: %w", circuit.ID, err)
+		return nil, err
 	}
-	rowsAffected, err := res.RowsAffected()
+	previous := &entity.Circuit{ID: circuit.ID, Version: int32(version - 1)}
+	if err := tx.QueryRowContext(ctx, "SELECT title FROM circuits WHERE id = $1", circuit.ID).Scan(&previous.Title); err != nil {
+		return nil, fmt.Errorf("failed to query circuit %s: %w", circuit.ID, err)
+	}
+	if previous.Nodes, err = c.fetchNodesForCircuit(ctx, tx, circuit.ID); err != nil {
+		return nil, err
+	}
+	if previous.Edges, err = c.fetchEdgesForCircuit(ctx, tx, circuit.ID); err != nil {
+		return nil, err
+	}
+
+	// 2. Update the title
+	_, err = tx.ExecContext(ctx, "UPDATE circuits SET title = $1 WHERE id = $2", circuit.Title, circuit.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected for circuit update %s: %w", circuit.ID, err)
+		return nil, fmt.Errorf("failed to update circuit %s: %w", circuit.ID, err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("circuit with id %s not found for update", circuit.ID)
+	circuit.Version = int32(version)
+
+	// 3. Delete old nodes (ON DELETE CASCADE in the DB will handle deleting associated edges)
+	_, err = tx.ExecContext(ctx, "DELETE FROM nodes WHERE circuit_id = $1", circuit.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete old nodes for circuit %s: %w", circuit.ID, err)
+	}
+
+	// 4. Insert new nodes and edges
+	if err := c.upsertNodesAndEdges(ctx, tx, circuit); err != nil {
+		return nil, err
 	}
 
-	// 2. Delete old nodes (ON DELETE CASCADE in the DB will handle deleting associated edges)
-	_, err = tx.Exec("DELETE FROM nodes WHERE circuit_id = $1", circuit.ID)
+	// 5. Wholesale replacement is not invertible edit-by-edit, so it starts a fresh
+	// operation log. The previous state stays available as a revision.
+	_, err = tx.ExecContext(ctx, "DELETE FROM circuit_operations WHERE circuit_id = $1", circuit.ID)
 	if err != nil {
-		return fmt.Errorf("failed to delete old nodes for circuit %s: %w", circuit.ID, err)
+		return nil, fmt.Errorf("failed to clear operation log for circuit %s: %w", circuit.ID, err)
 	}
 
-	// 3. Insert new nodes and edges
-	if err := c.upsertNodesAndEdges(tx, circuit); err != nil {
-		return err
+	// 6. Snapshot the result as a new revision
+	if err := c.recordRevision(ctx, tx, circuit.ID); err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return previous, nil
 }
 
-func (c circuitRepositoryImpl) DeleteCircuit(id string) error {
-	res, err := DB.Exec("DELETE FROM circuits WHERE id = $1", id)
+func (c circuitRepositoryImpl) DeleteCircuit(ctx context.Context, id string) error {
+	res, err := DB.ExecContext(ctx, "DELETE FROM circuits WHERE id = $1", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete circuit %s: %w", id, err)
 	}
@@ -283,45 +448,46 @@ func NewCircuitRepository() CircuitRepository {
 
 // --- Helper Functions ---
 
-func (c circuitRepositoryImpl) getCircuitRecursive(id string, visited map[string]bool) (*entity.Circuit, error) {
-	if visited[id] {
-		// If we've already visited this circuit in this call stack,
-		// return a shallow circuit to break the recursion.
-		return &entity.Circuit{ID: id, Title: "Recursive Reference"}, nil
-	}
-	visited[id] = true
-	defer delete(visited, id) // Clean up for other branches of the call tree
-
-	circuit := &entity.Circuit{ID: id}
-
-	// Fetch circuit details
-	err := DB.QueryRow("SELECT title FROM circuits WHERE id = $1", id).Scan(&circuit.Title)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("circuit with id %s not found", id)
-		}
-		return nil, fmt.Errorf("failed to query circuit %s: %w", id, err)
-	}
-
-	// Fetch nodes
-	nodes, err := c.fetchNodesForCircuit(id, visited)
-	if err != nil {
-		return nil, err
+// bumpVersion increments the circuit's version and returns the new value. The
+// UPDATE also takes the row lock, serializing concurrent writers to the circuit.
+// If expectedVersion is set and the circuit has moved on, an *entity.ConflictError
+// is returned and nothing is written.
+func (c circuitRepositoryImpl) bumpVersion(ctx context.Context, tx *sql.Tx, circuitID string, expectedVersion *int) (int, error) {
+	var version int
+	err := tx.QueryRowContext(ctx,
+		"UPDATE circuits SET version = version + 1 WHERE id = $1 AND ($2::int IS NULL OR version = $2) RETURNING version",
+		circuitID, expectedVersion,
+	).Scan(&version)
+	if err == nil {
+		return version, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to update version of circuit %s: %w", circuitID, err)
+	}
+
+	// Nothing matched: either the circuit is missing or the version check failed
+	var current int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM circuits WHERE id = $1", circuitID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("circuit with id %s not found", circuitID)
 	}
-	circuit.Nodes = nodes
-
-	// Fetch edges
-	edges, err := c.fetchEdgesForCircuit(id)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to query version of circuit %s: %w", circuitID, err)
 	}
-	circuit.Edges = edges
+	return 0, &entity.ConflictError{CircuitID: circuitID, ExpectedVersion: *expectedVersion, CurrentVersion: current}
+}
 
-	return circuit, nil
+// queryer is satisfied by both *sql.DB and *sql.Tx, so read helpers can run
+// either standalone or inside a write transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
-func (c circuitRepositoryImpl) fetchNodesForCircuit(circuitID string, visited map[string]bool) ([]entity.Node, error) {
-	rows, err := DB.Query("SELECT id, type, title, referenced_circuit_id FROM nodes WHERE circuit_id = $1", circuitID)
+// fetchNodesForCircuit loads the nodes of a single circuit. Circuits referenced by
+// CircuitNodes are left as placeholders; the GraphQL layer resolves them lazily.
+func (c circuitRepositoryImpl) fetchNodesForCircuit(ctx context.Context, q queryer, circuitID string) ([]entity.Node, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, type, title, referenced_circuit_id FROM nodes WHERE circuit_id = $1 ORDER BY id", circuitID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query nodes for circuit %s: %w", circuitID, err)
 	}
@@ -335,33 +501,8 @@ func (c circuitRepositoryImpl) fetchNodesForCircuit(circuitID string, visited ma
 			return nil, fmt.Errorf("failed to scan node row: %w", err)
 		}
 
-		var node entity.Node
-		switch nodeType {
-		case "INPUT":
-			node = &entity.InputNode{ID: id, Title: title.String}
-		case "OUTPUT":
-			node = &entity.OutputNode{ID: id, Title: title.String}
-		case "AND":
-			node = &entity.AndNode{ID: id}
-		case "OR":
-			node = &entity.OrNode{ID: id}
-		case "NOT":
-			node = &entity.NotNode{ID: id}
-		case "CIRCUIT":
-			cn := &entity.CircuitNode{ID: id}
-			if referencedCircuitID.Valid && referencedCircuitID.String != "" {
-				// Recursively fetch the nested circuit
-				nestedCircuit, err := c.getCircuitRecursive(referencedCircuitID.String, visited)
-				if err != nil {
-					log.Printf("warning: failed to fetch nested circuit %s for node %s: %v", referencedCircuitID.String, id, err)
-					cn.Circuit = &entity.Circuit{ID: referencedCircuitID.String, Title: "Not Found"}
-				} else {
-					cn.Circuit = nestedCircuit
-				}
-			}
-			node = cn
-		default:
-			log.Printf("warning: unknown node type '%s' found in database for circuit %s", nodeType, circuitID)
+		node := c.createNodeFromDB(id, nodeType, title, referencedCircuitID)
+		if node == nil {
 			continue // Skip unknown node types
 		}
 		nodes = append(nodes, node)
@@ -369,8 +510,8 @@ func (c circuitRepositoryImpl) fetchNodesForCircuit(circuitID string, visited ma
 	return nodes, rows.Err()
 }
 
-func (c circuitRepositoryImpl) fetchEdgesForCircuit(circuitID string) ([]*entity.Edge, error) {
-	rows, err := DB.Query("SELECT id, source_node_id, target_node_id FROM edges WHERE circuit_id = $1", circuitID)
+func (c circuitRepositoryImpl) fetchEdgesForCircuit(ctx context.Context, q queryer, circuitID string) ([]*entity.Edge, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, source_node_id, target_node_id, COALESCE(source_port, ''), COALESCE(target_port, '') FROM edges WHERE circuit_id = $1 ORDER BY id", circuitID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query edges for circuit %s: %w", circuitID, err)
 	}
@@ -379,7 +520,7 @@ func (c circuitRepositoryImpl) fetchEdgesForCircuit(circuitID string) ([]*entity
 	var edges []*entity.Edge
 	for rows.Next() {
 		edge := &entity.Edge{}
-		if err := rows.Scan(&edge.ID, &edge.SourceNodeID, &edge.TargetNodeID); err != nil {
+		if err := rows.Scan(&edge.ID, &edge.SourceNodeID, &edge.TargetNodeID, &edge.SourcePort, &edge.TargetPort); err != nil {
 			return nil, fmt.Errorf("failed to scan edge row: %w", err)
 		}
 		edges = append(edges, edge)
@@ -388,72 +529,115 @@ func (c circuitRepositoryImpl) fetchEdgesForCircuit(circuitID string) ([]*entity
 }
 
 // upsertNodesAndEdges is a helper to insert nodes and edges for a circuit within a transaction.
-func (c circuitRepositoryImpl) upsertNodesAndEdges(tx *sql.Tx, circuit *entity.Circuit) error {
+func (c circuitRepositoryImpl) upsertNodesAndEdges(ctx context.Context, tx *sql.Tx, circuit *entity.Circuit) error {
 	// Insert nodes
 	for _, node := range circuit.Nodes {
-		if err := c.insertNode(tx, circuit.ID, node); err != nil {
+		if err := c.insertNode(ctx, tx, circuit.ID, node); err != nil {
 			return err // error is already descriptive
 		}
 	}
 
 	// Insert edges
 	for _, edge := range circuit.Edges {
-		if edge.ID == "" {
-			edge.ID = uuid.New().String()
-		}
-		_, err := tx.Exec(
-			"INSERT INTO edges (id, circuit_id, source_node_id, target_node_id) VALUES ($1, $2, $3, $4)",
-			edge.ID, circuit.ID, edge.SourceNodeID, edge.TargetNodeID,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert edge %s: %w", edge.ID, err)
+		if err := c.insertEdge(ctx, tx, circuit.ID, edge); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// insertEdge is a helper to insert an edge, assigning it a UUID if it doesn't have one.
+func (c circuitRepositoryImpl) insertEdge(ctx context.Context, tx *sql.Tx, circuitID string, edge *entity.Edge) error {
+	if edge.ID == "" {
+		edge.ID = uuid.New().String()
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO edges (id, circuit_id, source_node_id, target_node_id, source_port, target_port) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))",
+		edge.ID, circuitID, edge.SourceNodeID, edge.TargetNodeID, edge.SourcePort, edge.TargetPort,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert edge %s: %w", edge.ID, err)
+	}
+	return nil
+}
+
 // insertNode is a helper to insert a generic entity.Node into the database.
-func (c circuitRepositoryImpl) insertNode(tx *sql.Tx, circuitID string, node entity.Node) error {
-	var nodeType, title, referencedCircuitID sql.NullString
-	var nodeID string
+func (c circuitRepositoryImpl) insertNode(ctx context.Context, tx *sql.Tx, circuitID string, node entity.Node) error {
+	nodeID, nodeType, title, referencedCircuitID, err := c.nodeColumns(node)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO nodes (id, circuit_id, type, title, referenced_circuit_id) VALUES ($1, $2, $3, $4, $5)",
+		nodeID, circuitID, nodeType, title, referencedCircuitID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert node %s: %w", nodeID, err)
+	}
+	return nil
+}
 
+// nodeColumns maps a generic entity.Node onto its column values in the nodes table.
+// It is the inverse of createNodeFromDB.
+func (c circuitRepositoryImpl) nodeColumns(node entity.Node) (nodeID, nodeType string, title, referencedCircuitID sql.NullString, err error) {
 	// This function assigns a new UUID to the node's ID field.
 	// This is crucial because edge creation relies on these IDs.
 	switch n := node.(type) {
 	case *entity.InputNode:
-		nodeType.String, nodeType.Valid = "INPUT", true
+		nodeType = "INPUT"
 		title.String, title.Valid = n.Title, true
 		if n.ID == "" {
 			n.ID = uuid.New().String()
 		}
 		nodeID = n.ID
 	case *entity.OutputNode:
-		nodeType.String, nodeType.Valid = "OUTPUT", true
+		nodeType = "OUTPUT"
 		title.String, title.Valid = n.Title, true
 		if n.ID == "" {
 			n.ID = uuid.New().String()
 		}
 		nodeID = n.ID
 	case *entity.AndNode:
-		nodeType.String, nodeType.Valid = "AND", true
+		nodeType = "AND"
 		if n.ID == "" {
 			n.ID = uuid.New().String()
 		}
 		nodeID = n.ID
 	case *entity.OrNode:
-		nodeType.String, nodeType.Valid = "OR", true
+		nodeType = "OR"
 		if n.ID == "" {
 			n.ID = uuid.New().String()
 		}
 		nodeID = n.ID
 	case *entity.NotNode:
-		nodeType.String, nodeType.Valid = "NOT", true
+		nodeType = "NOT"
+		if n.ID == "" {
+			n.ID = uuid.New().String()
+		}
+		nodeID = n.ID
+	case *entity.TriStateNode:
+		nodeType = "TRISTATE"
+		if n.ID == "" {
+			n.ID = uuid.New().String()
+		}
+		nodeID = n.ID
+	case *entity.DFlipFlopNode:
+		nodeType = "DFF"
+		title.String, title.Valid = n.Title, true
+		if n.ID == "" {
+			n.ID = uuid.New().String()
+		}
+		nodeID = n.ID
+	case *entity.SRLatchNode:
+		nodeType = "SRLATCH"
+		title.String, title.Valid = n.Title, true
 		if n.ID == "" {
 			n.ID = uuid.New().String()
 		}
 		nodeID = n.ID
 	case *entity.CircuitNode:
-		nodeType.String, nodeType.Valid = "CIRCUIT", true
+		nodeType = "CIRCUIT"
 		if n.Circuit != nil && n.Circuit.ID != "" {
 			referencedCircuitID.String, referencedCircuitID.Valid = n.Circuit.ID, true
 		}
@@ -462,17 +646,9 @@ func (c circuitRepositoryImpl) insertNode(tx *sql.Tx, circuitID string, node ent
 		}
 		nodeID = n.ID
 	default:
-		return fmt.Errorf("unknown node type: %T", n)
-	}
-
-	_, err := tx.Exec(
-		"INSERT INTO nodes (id, circuit_id, type, title, referenced_circuit_id) VALUES ($1, $2, $3, $4, $5)",
-		nodeID, circuitID, nodeType.String, title, referencedCircuitID,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to insert node %s: %w", nodeID, err)
+		err = fmt.Errorf("unknown node type: %T", n)
 	}
-	return nil
+	return
 }
 
 // createNodeFromDB creates a node entity from database row data
@@ -494,11 +670,23 @@ func (c circuitRepositoryImpl) createNodeFromDB(id, nodeType string, title, refe
 		return &entity.OrNode{ID: id}
 	case "NOT":
 		return &entity.NotNode{ID: id}
+	case "TRISTATE":
+		return &entity.TriStateNode{ID: id}
+	case "DFF":
+		return &entity.DFlipFlopNode{
+			ID:    id,
+			Title: title.String,
+		}
+	case "SRLATCH":
+		return &entity.SRLatchNode{
+			ID:    id,
+			Title: title.String,
+		}
 	case "CIRCUIT":
 		circuitNode := &entity.CircuitNode{ID: id}
 		if referencedCircuitID.Valid && referencedCircuitID.String != "" {
 			// For circuit nodes, we'll create a placeholder circuit
-			// The actual circuit data would need to be loaded separately if needed
+			// The actual circuit data is loaded separately when it is requested
 			circuitNode.Circuit = &entity.Circuit{
 				ID:    referencedCircuitID.String,
 				Title: "Referenced Circuit",
@@ -0,0 +1,199 @@
+package data
+
+import (
+	"backend/internal/entity"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// circuitSnapshot is the serialized form of a circuit stored with each revision.
+// Snapshots are gzip-compressed JSON so that long histories stay compact.
+type circuitSnapshot struct {
+	Title string         `json:"title"`
+	Nodes []snapshotNode `json:"nodes"`
+	Edges []snapshotEdge `json:"edges"`
+}
+
+type snapshotNode struct {
+	ID                  string  `json:"id"`
+	Type                string  `json:"type"`
+	Title               *string `json:"title,omitempty"`
+	ReferencedCircuitID *string `json:"ref,omitempty"`
+}
+
+func newSnapshotNode(nodeID, nodeType string, title, referencedCircuitID sql.NullString) snapshotNode {
+	sn := snapshotNode{ID: nodeID, Type: nodeType}
+	if title.Valid {
+		sn.Title = &title.String
+	}
+	if referencedCircuitID.Valid {
+		sn.ReferencedCircuitID = &referencedCircuitID.String
+	}
+	return sn
+}
+
+// toNode rebuilds the entity.Node, or returns nil for an unknown type.
+func (sn snapshotNode) toNode(c circuitRepositoryImpl) entity.Node {
+	var title, referencedCircuitID sql.NullString
+	if sn.Title != nil {
+		title.String, title.Valid = *sn.Title, true
+	}
+	if sn.ReferencedCircuitID != nil {
+		referencedCircuitID.String, referencedCircuitID.Valid = *sn.ReferencedCircuitID, true
+	}
+	return c.createNodeFromDB(sn.ID, sn.Type, title, referencedCircuitID)
+}
+
+type snapshotEdge struct {
+	ID           string `json:"id"`
+	SourceNodeID string `json:"src"`
+	TargetNodeID string `json:"dst"`
+	SourcePort   string `json:"srcPort,omitempty"`
+	TargetPort   string `json:"port,omitempty"`
+}
+
+func newSnapshotEdge(edge *entity.Edge) snapshotEdge {
+	return snapshotEdge{ID: edge.ID, SourceNodeID: edge.SourceNodeID, TargetNodeID: edge.TargetNodeID, SourcePort: edge.SourcePort, TargetPort: edge.TargetPort}
+}
+
+func (se snapshotEdge) toEdge() *entity.Edge {
+	return &entity.Edge{ID: se.ID, SourceNodeID: se.SourceNodeID, TargetNodeID: se.TargetNodeID, SourcePort: se.SourcePort, TargetPort: se.TargetPort}
+}
+
+func (c circuitRepositoryImpl) GetCircuitRevisions(ctx context.Context, circuitID string) ([]*entity.CircuitRevision, error) {
+	rows, err := DB.QueryContext(ctx,
+		"SELECT revision, title, created_at FROM circuit_revisions WHERE circuit_id = $1 ORDER BY revision DESC",
+		circuitID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions for circuit %s: %w", circuitID, err)
+	}
+	defer rows.Close()
+
+	revisions := []*entity.CircuitRevision{}
+	for rows.Next() {
+		revision := &entity.CircuitRevision{CircuitID: circuitID}
+		if err := rows.Scan(&revision.Revision, &revision.Title, &revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision row: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (c circuitRepositoryImpl) GetCircuitRevision(ctx context.Context, circuitID string, revision int) (*entity.Circuit, error) {
+	var data []byte
+	err := DB.QueryRowContext(ctx,
+		"SELECT snapshot FROM circuit_revisions WHERE circuit_id = $1 AND revision = $2",
+		circuitID, revision,
+	).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("revision %d of circuit %s not found", revision, circuitID)
+		}
+		return nil, fmt.Errorf("failed to query revision %d of circuit %s: %w", revision, circuitID, err)
+	}
+
+	circuit, err := c.decodeSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d of circuit %s: %w", revision, circuitID, err)
+	}
+	circuit.ID = circuitID
+	circuit.Version = int32(revision)
+	return circuit, nil
+}
+
+// recordRevision snapshots the circuit as seen inside tx and stores it under the
+// circuit's current version, so revision numbers and versions always agree.
+// Callers must already have bumped the version (see bumpVersion).
+func (c circuitRepositoryImpl) recordRevision(ctx context.Context, tx *sql.Tx, circuitID string) error {
+	snapshot := circuitSnapshot{Nodes: []snapshotNode{}, Edges: []snapshotEdge{}}
+	var version int
+	err := tx.QueryRowContext(ctx, "SELECT title, version FROM circuits WHERE id = $1", circuitID).Scan(&snapshot.Title, &version)
+	if err != nil {
+		return fmt.Errorf("failed to query circuit %s for revision: %w", circuitID, err)
+	}
+
+	nodes, err := c.fetchNodesForCircuit(ctx, tx, circuitID)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		nodeID, nodeType, title, referencedCircuitID, err := c.nodeColumns(node)
+		if err != nil {
+			return err
+		}
+		snapshot.Nodes = append(snapshot.Nodes, newSnapshotNode(nodeID, nodeType, title, referencedCircuitID))
+	}
+
+	edges, err := c.fetchEdgesForCircuit(ctx, tx, circuitID)
+	if err != nil {
+		return err
+	}
+	for _, edge := range edges {
+		snapshot.Edges = append(snapshot.Edges, newSnapshotEdge(edge))
+	}
+
+	data, err := encodeSnapshot(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision for circuit %s: %w", circuitID, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO circuit_revisions (circuit_id, revision, title, snapshot) VALUES ($1, $2, $3, $4)",
+		circuitID, version, snapshot.Title, data,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert revision for circuit %s: %w", circuitID, err)
+	}
+	return nil
+}
+
+func encodeSnapshot(snapshot circuitSnapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c circuitRepositoryImpl) decodeSnapshot(data []byte) (*entity.Circuit, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot circuitSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, err
+	}
+
+	circuit := &entity.Circuit{
+		Title: snapshot.Title,
+		Nodes: []entity.Node{},
+		Edges: []*entity.Edge{},
+	}
+	for _, sn := range snapshot.Nodes {
+		if node := sn.toNode(c); node != nil {
+			circuit.Nodes = append(circuit.Nodes, node)
+		}
+	}
+	for _, se := range snapshot.Edges {
+		circuit.Edges = append(circuit.Edges, se.toEdge())
+	}
+	return circuit, nil
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"backend/internal/entity"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+func (c circuitRepositoryImpl) AddCircuitTest(ctx context.Context, test *entity.CircuitTest) error {
+	inputs, err := json.Marshal(test.Inputs)
+	if err != nil {
+		return fmt.Errorf("failed to encode inputs of test %s: %w", test.Name, err)
+	}
+	expected, err := json.Marshal(test.Expected)
+	if err != nil {
+		return fmt.Errorf("failed to encode expected outputs of test %s: %w", test.Name, err)
+	}
+
+	err = DB.QueryRowContext(ctx,
+		"INSERT INTO circuit_tests (id, circuit_id, name, inputs, expected) VALUES ($1, $2, $3, $4, $5) RETURNING created_at",
+		test.ID, test.CircuitID, test.Name, inputs, expected,
+	).Scan(&test.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert test %s: %w", test.Name, err)
+	}
+	return nil
+}
+
+func (c circuitRepositoryImpl) GetCircuitTests(ctx context.Context, circuitID string) ([]*entity.CircuitTest, error) {
+	rows, err := DB.QueryContext(ctx,
+		"SELECT id, name, inputs, expected, created_at FROM circuit_tests WHERE circuit_id = $1 ORDER BY name",
+		circuitID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tests for circuit %s: %w", circuitID, err)
+	}
+	defer rows.Close()
+
+	tests := []*entity.CircuitTest{}
+	for rows.Next() {
+		test := &entity.CircuitTest{CircuitID: circuitID}
+		var inputs, expected []byte
+		if err := rows.Scan(&test.ID, &test.Name, &inputs, &expected, &test.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan test row: %w", err)
+		}
+		if err := json.Unmarshal(inputs, &test.Inputs); err != nil {
+			return nil, fmt.Errorf("failed to decode inputs of test %s: %w", test.ID, err)
+		}
+		if err := json.Unmarshal(expected, &test.Expected); err != nil {
+			return nil, fmt.Errorf("failed to decode expected outputs of test %s: %w", test.ID, err)
+		}
+		tests = append(tests, test)
+	}
+	return tests, rows.Err()
+}
+
+func (c circuitRepositoryImpl) DeleteCircuitTest(ctx context.Context, circuitID string, testID string) error {
+	result, err := DB.ExecContext(ctx, "DELETE FROM circuit_tests WHERE circuit_id = $1 AND id = $2", circuitID, testID)
+	if err != nil {
+		return fmt.Errorf("failed to delete test %s: %w", testID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("test %s not found in circuit %s", testID, circuitID)
+	}
+	return nil
+}
+
+func (c circuitRepositoryImpl) AddCircuitTestRun(ctx context.Context, run *entity.CircuitTestRun) error {
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return fmt.Errorf("failed to encode test results: %w", err)
+	}
+
+	err = DB.QueryRowContext(ctx,
+		`INSERT INTO circuit_test_runs (id, circuit_id, circuit_version, passed, total, failed, results)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ran_at`,
+		run.ID, run.CircuitID, run.CircuitVersion, run.Passed, run.Total, run.Failed, results,
+	).Scan(&run.RanAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert test run for circuit %s: %w", run.CircuitID, err)
+	}
+	return nil
+}
+
+func (c circuitRepositoryImpl) GetCircuitTestRuns(ctx context.Context, circuitID string, limit int) ([]*entity.CircuitTestRun, error) {
+	rows, err := DB.QueryContext(ctx,
+		`SELECT id, circuit_version, passed, total, failed, results, ran_at FROM circuit_test_runs
+		WHERE circuit_id = $1 ORDER BY ran_at DESC, id LIMIT $2`,
+		circuitID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test runs for circuit %s: %w", circuitID, err)
+	}
+	defer rows.Close()
+
+	runs := []*entity.CircuitTestRun{}
+	for rows.Next() {
+		run := &entity.CircuitTestRun{CircuitID: circuitID}
+		var results []byte
+		if err := rows.Scan(&run.ID, &run.CircuitVersion, &run.Passed, &run.Total, &run.Failed, &results, &run.RanAt); err != nil {
+			return nil, fmt.Errorf("failed to scan test run row: %w", err)
+		}
+		if err := json.Unmarshal(results, &run.Results); err != nil {
+			return nil, fmt.Errorf("failed to decode results of test run %s: %w", run.ID, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
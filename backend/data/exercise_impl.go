@@ -0,0 +1,157 @@
+package data
+
+import (
+	"backend/internal/entity"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+const exerciseColumns = `id, title, description, inputs, outputs, truth_table,
+	COALESCE(reference_circuit_id::text, ''), allowed_gate_types, max_gates, max_depth, created_at`
+
+func (c circuitRepositoryImpl) CreateExercise(ctx context.Context, exercise *entity.Exercise) error {
+	inputs, err := json.Marshal(exercise.Inputs)
+	if err != nil {
+		return fmt.Errorf("failed to encode inputs of exercise %s: %w", exercise.Title, err)
+	}
+	outputs, err := json.Marshal(exercise.Outputs)
+	if err != nil {
+		return fmt.Errorf("failed to encode outputs of exercise %s: %w", exercise.Title, err)
+	}
+	var truthTable []byte
+	if len(exercise.TruthTable) > 0 {
+		if truthTable, err = json.Marshal(exercise.TruthTable); err != nil {
+			return fmt.Errorf("failed to encode truth table of exercise %s: %w", exercise.Title, err)
+		}
+	}
+	allowedGateTypes, err := json.Marshal(exercise.AllowedGateTypes)
+	if err != nil {
+		return fmt.Errorf("failed to encode allowed gate types of exercise %s: %w", exercise.Title, err)
+	}
+
+	err = DB.QueryRowContext(ctx,
+		`INSERT INTO exercises (id, title, description, inputs, outputs, truth_table, reference_circuit_id, allowed_gate_types, max_gates, max_depth)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid, $8, $9, $10) RETURNING created_at`,
+		exercise.ID, exercise.Title, exercise.Description, inputs, outputs, truthTable,
+		exercise.ReferenceCircuitID, allowedGateTypes, exercise.MaxGates, exercise.MaxDepth,
+	).Scan(&exercise.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert exercise %s: %w", exercise.Title, err)
+	}
+	return nil
+}
+
+func (c circuitRepositoryImpl) GetExercise(ctx context.Context, id string) (*entity.Exercise, error) {
+	exercise, err := scanExercise(DB.QueryRowContext(ctx, "SELECT "+exerciseColumns+" FROM exercises WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("exercise with id %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exercise %s: %w", id, err)
+	}
+	return exercise, nil
+}
+
+func (c circuitRepositoryImpl) GetAllExercises(ctx context.Context) ([]*entity.Exercise, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT "+exerciseColumns+" FROM exercises ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exercises: %w", err)
+	}
+	defer rows.Close()
+
+	exercises := []*entity.Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exercise row: %w", err)
+		}
+		exercises = append(exercises, exercise)
+	}
+	return exercises, rows.Err()
+}
+
+// scanExercise reads a row selected with exerciseColumns.
+func scanExercise(row interface{ Scan(...interface{}) error }) (*entity.Exercise, error) {
+	exercise := &entity.Exercise{}
+	var inputs, outputs, truthTable, allowedGateTypes []byte
+	err := row.Scan(&exercise.ID, &exercise.Title, &exercise.Description, &inputs, &outputs, &truthTable,
+		&exercise.ReferenceCircuitID, &allowedGateTypes, &exercise.MaxGates, &exercise.MaxDepth, &exercise.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(inputs, &exercise.Inputs); err != nil {
+		return nil, fmt.Errorf("failed to decode inputs of exercise %s: %w", exercise.ID, err)
+	}
+	if err := json.Unmarshal(outputs, &exercise.Outputs); err != nil {
+		return nil, fmt.Errorf("failed to decode outputs of exercise %s: %w", exercise.ID, err)
+	}
+	// The truth table is NULL for exercises graded against a reference circuit.
+	if truthTable != nil {
+		if err := json.Unmarshal(truthTable, &exercise.TruthTable); err != nil {
+			return nil, fmt.Errorf("failed to decode truth table of exercise %s: %w", exercise.ID, err)
+		}
+	}
+	if err := json.Unmarshal(allowedGateTypes, &exercise.AllowedGateTypes); err != nil {
+		return nil, fmt.Errorf("failed to decode allowed gate types of exercise %s: %w", exercise.ID, err)
+	}
+	return exercise, nil
+}
+
+func (c circuitRepositoryImpl) AddExerciseSubmission(ctx context.Context, submission *entity.ExerciseSubmission) error {
+	failingRows, err := json.Marshal(submission.FailingRows)
+	if err != nil {
+		return fmt.Errorf("failed to encode failing rows: %w", err)
+	}
+	violations, err := json.Marshal(submission.Violations)
+	if err != nil {
+		return fmt.Errorf("failed to encode constraint violations: %w", err)
+	}
+
+	err = DB.QueryRowContext(ctx,
+		`INSERT INTO exercise_submissions (id, exercise_id, circuit_id, circuit_version, score, passed,
+			rows_checked, rows_failed, failing_rows, violations, gate_count, depth)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING submitted_at`,
+		submission.ID, submission.ExerciseID, submission.CircuitID, submission.CircuitVersion, submission.Score, submission.Passed,
+		submission.RowsChecked, submission.RowsFailed, failingRows, violations, submission.GateCount, submission.Depth,
+	).Scan(&submission.SubmittedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert submission for exercise %s: %w", submission.ExerciseID, err)
+	}
+	return nil
+}
+
+func (c circuitRepositoryImpl) GetExerciseSubmissions(ctx context.Context, exerciseID string, limit int) ([]*entity.ExerciseSubmission, error) {
+	rows, err := DB.QueryContext(ctx,
+		`SELECT id, circuit_id, circuit_version, score, passed, rows_checked, rows_failed,
+			failing_rows, violations, gate_count, depth, submitted_at
+		FROM exercise_submissions WHERE exercise_id = $1 ORDER BY submitted_at DESC, id LIMIT $2`,
+		exerciseID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query submissions for exercise %s: %w", exerciseID, err)
+	}
+	defer rows.Close()
+
+	submissions := []*entity.ExerciseSubmission{}
+	for rows.Next() {
+		submission := &entity.ExerciseSubmission{ExerciseID: exerciseID}
+		var failingRows, violations []byte
+		err := rows.Scan(&submission.ID, &submission.CircuitID, &submission.CircuitVersion, &submission.Score, &submission.Passed,
+			&submission.RowsChecked, &submission.RowsFailed, &failingRows, &violations,
+			&submission.GateCount, &submission.Depth, &submission.SubmittedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan submission row: %w", err)
+		}
+		if err := json.Unmarshal(failingRows, &submission.FailingRows); err != nil {
+			return nil, fmt.Errorf("failed to decode failing rows of submission %s: %w", submission.ID, err)
+		}
+		if err := json.Unmarshal(violations, &submission.Violations); err != nil {
+			return nil, fmt.Errorf("failed to decode violations of submission %s: %w", submission.ID, err)
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, rows.Err()
+}
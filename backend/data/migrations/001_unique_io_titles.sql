@@ -0,0 +1,34 @@
+-- Adds the unique index on input and output titles to a database created before it.
+-- Nodes whose title another input (or output) of the same circuit already has are
+-- renamed to "<title> (2)", "<title> (3)", ... in ID order, skipping titles in use.
+BEGIN;
+DO $$
+DECLARE
+    duplicate RECORD;
+    suffix INTEGER;
+BEGIN
+    FOR duplicate IN
+        SELECT id, circuit_id, type, title
+        FROM (
+            SELECT id, circuit_id, type, title,
+                row_number() OVER (PARTITION BY circuit_id, type, title ORDER BY id) AS n
+            FROM nodes
+            WHERE type IN ('INPUT', 'OUTPUT') AND title <> ''
+        ) ranked
+        WHERE n > 1
+        ORDER BY circuit_id, type, title, id
+    LOOP
+        suffix := 2;
+        WHILE EXISTS (
+            SELECT 1 FROM nodes
+            WHERE circuit_id = duplicate.circuit_id AND type = duplicate.type
+                AND title = duplicate.title || ' (' || suffix || ')'
+        ) LOOP
+            suffix := suffix + 1;
+        END LOOP;
+        UPDATE nodes SET title = duplicate.title || ' (' || suffix || ')' WHERE id = duplicate.id;
+    END LOOP;
+END $$;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nodes_io_title ON nodes (circuit_id, type, title)
+WHERE type IN ('INPUT', 'OUTPUT') AND title <> '';
+COMMIT;
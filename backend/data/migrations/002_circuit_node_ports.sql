@@ -0,0 +1,34 @@
+-- Adds edge ports to a database created before them, and names the port of every
+-- port-less edge into or out of a circuit node whose referenced circuit has a single
+-- titled input (or output). Such edges keep working without a port, but only while
+-- the referenced circuit has a single input (or output).
+BEGIN;
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS source_port TEXT;
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS target_port TEXT;
+UPDATE edges
+SET source_port = port.title
+FROM nodes circuit_node,
+    LATERAL (
+        SELECT min(n.title) AS title
+        FROM nodes n
+        WHERE n.circuit_id = circuit_node.referenced_circuit_id AND n.type = 'OUTPUT'
+        HAVING count(*) = 1
+    ) port
+WHERE edges.source_node_id = circuit_node.id
+    AND circuit_node.type = 'CIRCUIT'
+    AND COALESCE(edges.source_port, '') = ''
+    AND port.title <> '';
+UPDATE edges
+SET target_port = port.title
+FROM nodes circuit_node,
+    LATERAL (
+        SELECT min(n.title) AS title
+        FROM nodes n
+        WHERE n.circuit_id = circuit_node.referenced_circuit_id AND n.type = 'INPUT'
+        HAVING count(*) = 1
+    ) port
+WHERE edges.target_node_id = circuit_node.id
+    AND circuit_node.type = 'CIRCUIT'
+    AND COALESCE(edges.target_port, '') = ''
+    AND port.title <> '';
+COMMIT;
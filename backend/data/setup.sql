@@ -1,3 +1,9 @@
+DROP TABLE IF EXISTS exercise_submissions;
+DROP TABLE IF EXISTS exercises;
+DROP TABLE IF EXISTS circuit_test_runs;
+DROP TABLE IF EXISTS circuit_tests;
+DROP TABLE IF EXISTS circuit_operations;
+DROP TABLE IF EXISTS circuit_revisions;
 DROP TABLE IF EXISTS edges;
 DROP TABLE IF EXISTS nodes;
 DROP TABLE IF EXISTS circuits;
@@ -8,11 +14,16 @@ CREATE TYPE node_type AS ENUM (
     'AND',
     'OR',
     'NOT',
+    'TRISTATE',
+    'DFF',
+    'SRLATCH',
     'CIRCUIT'
 );
 CREATE TABLE circuits (
     id UUID PRIMARY KEY,
-    title TEXT NOT NULL
+    title TEXT NOT NULL,
+    -- Incremented on every change; used for optimistic concurrency control
+    version INTEGER NOT NULL DEFAULT 1
 );
 -- Stores all nodes for all circuits. A 'type' column differentiates them.
 CREATE TABLE nodes (
@@ -30,13 +41,107 @@ CREATE TABLE edges (
     circuit_id UUID NOT NULL,
     source_node_id UUID NOT NULL,
     target_node_id UUID NOT NULL,
+    -- Output port of the source node, for circuit nodes (the title of an output of the referenced circuit).
+    -- migrations/002_circuit_node_ports.sql adds the ports to existing databases.
+    source_port TEXT,
+    -- Input port of the target node, for nodes with distinct inputs (e.g. a tri-state buffer's data and enable)
+    target_port TEXT,
     CONSTRAINT fk_circuit FOREIGN KEY (circuit_id) REFERENCES circuits (id) ON DELETE CASCADE,
     CONSTRAINT fk_source_node FOREIGN KEY (source_node_id) REFERENCES nodes (id) ON DELETE CASCADE,
     CONSTRAINT fk_target_node FOREIGN KEY (target_node_id) REFERENCES nodes (id) ON DELETE CASCADE
 );
+-- Immutable, numbered snapshots of each circuit. A new row is written on every change.
+-- The snapshot is gzip-compressed JSON of the circuit's title, nodes and edges.
+CREATE TABLE circuit_revisions (
+    circuit_id UUID NOT NULL,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    snapshot BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (circuit_id, revision),
+    CONSTRAINT fk_circuit FOREIGN KEY (circuit_id) REFERENCES circuits (id) ON DELETE CASCADE
+);
+-- Per-circuit log of invertible edits backing undo/redo. Undone operations are
+-- kept (undone = true) until a new edit discards them.
+CREATE TABLE circuit_operations (
+    id BIGSERIAL PRIMARY KEY,
+    circuit_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    undone BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_circuit FOREIGN KEY (circuit_id) REFERENCES circuits (id) ON DELETE CASCADE
+);
+-- Named test cases of a circuit. Inputs and expected outputs are stored as JSON
+-- arrays and address nodes by title, so tests survive edits that keep the titles.
+CREATE TABLE circuit_tests (
+    id UUID PRIMARY KEY,
+    circuit_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    inputs JSONB NOT NULL,
+    expected JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_circuit FOREIGN KEY (circuit_id) REFERENCES circuits (id) ON DELETE CASCADE,
+    CONSTRAINT uq_circuit_test_name UNIQUE (circuit_id, name)
+);
+-- History of test runs. Results holds the outcome of every test as JSON.
+CREATE TABLE circuit_test_runs (
+    id UUID PRIMARY KEY,
+    circuit_id UUID NOT NULL,
+    circuit_version INTEGER NOT NULL,
+    passed BOOLEAN NOT NULL,
+    total INTEGER NOT NULL,
+    failed INTEGER NOT NULL,
+    results JSONB NOT NULL,
+    ran_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_circuit FOREIGN KEY (circuit_id) REFERENCES circuits (id) ON DELETE CASCADE
+);
+-- Exercises ask for a circuit with the given inputs and outputs (by title) that matches
+-- a truth table, optionally within gate constraints. An exercise created from a
+-- reference circuit stores the reference's truth table.
+CREATE TABLE exercises (
+    id UUID PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    inputs JSONB NOT NULL,
+    outputs JSONB NOT NULL,
+    truth_table JSONB,
+    -- The circuit the truth table was computed from, which may since have changed or
+    -- been deleted
+    reference_circuit_id UUID,
+    allowed_gate_types JSONB NOT NULL,
+    max_gates INTEGER,
+    max_depth INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- Graded submissions. Failing rows and violations are stored as JSON.
+CREATE TABLE exercise_submissions (
+    id UUID PRIMARY KEY,
+    exercise_id UUID NOT NULL,
+    circuit_id UUID NOT NULL,
+    circuit_version INTEGER NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    passed BOOLEAN NOT NULL,
+    rows_checked INTEGER NOT NULL,
+    rows_failed INTEGER NOT NULL,
+    failing_rows JSONB NOT NULL,
+    violations JSONB NOT NULL,
+    gate_count INTEGER NOT NULL,
+    depth INTEGER NOT NULL,
+    submitted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_exercise FOREIGN KEY (exercise_id) REFERENCES exercises (id) ON DELETE CASCADE,
+    CONSTRAINT fk_circuit FOREIGN KEY (circuit_id) REFERENCES circuits (id) ON DELETE CASCADE
+);
 -- Add indexes on foreign keys to improve query performance.
 CREATE INDEX idx_nodes_circuit_id ON nodes (circuit_id);
 CREATE INDEX idx_nodes_referenced_circuit_id ON nodes (referenced_circuit_id);
+-- Inputs and outputs can be addressed by title during evaluation, so titles must be unique per circuit.
+-- migrations/001_unique_io_titles.sql renames duplicates in existing databases.
+CREATE UNIQUE INDEX idx_nodes_io_title ON nodes (circuit_id, type, title)
+WHERE type IN ('INPUT', 'OUTPUT') AND title <> '';
 CREATE INDEX idx_edges_circuit_id ON edges (circuit_id);
 CREATE INDEX idx_edges_source_node_id ON edges (source_node_id);
-CREATE INDEX idx_edges_target_node_id ON edges (target_node_id);
\ No newline at end of file
+CREATE INDEX idx_edges_target_node_id ON edges (target_node_id);
+CREATE INDEX idx_circuit_operations_circuit_id ON circuit_operations (circuit_id, id);
+CREATE INDEX idx_circuit_test_runs_circuit_id ON circuit_test_runs (circuit_id, ran_at DESC);
+CREATE INDEX idx_exercise_submissions_exercise_id ON exercise_submissions (exercise_id, submitted_at DESC);
\ No newline at end of file
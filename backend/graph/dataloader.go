@@ -0,0 +1,151 @@
+package graph
+
+import (
+	"backend/internal/entity"
+	"backend/internal/service"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// loaderWait is how long a loader collects keys before dispatching a batch.
+	loaderWait = 2 * time.Millisecond
+	// loaderMaxBatch caps the number of keys sent to a single fetch.
+	loaderMaxBatch = 100
+)
+
+// DataLoader batches and caches lookups by key for the lifetime of a single
+// GraphQL operation. Keys requested within loaderWait of each other are
+// fetched together, and every key is fetched at most once.
+type DataLoader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu    sync.Mutex
+	cache map[K]*loaderResult[V]
+	batch *loaderBatch[K, V]
+}
+
+type loaderResult[V any] struct {
+	value V
+	err   error
+	done  chan struct{}
+}
+
+type loaderBatch[K comparable, V any] struct {
+	keys    []K
+	results []*loaderResult[V]
+	closed  bool
+}
+
+func NewDataLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *DataLoader[K, V] {
+	return &DataLoader[K, V]{
+		fetch: fetch,
+		cache: make(map[K]*loaderResult[V]),
+	}
+}
+
+// Load returns the value for key, waiting for the batch it joins to be fetched.
+func (l *DataLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	result, ok := l.cache[key]
+	if !ok {
+		result = &loaderResult[V]{done: make(chan struct{})}
+		l.cache[key] = result
+
+		if l.batch == nil {
+			l.batch = &loaderBatch[K, V]{}
+			go l.dispatchAfterWait(ctx, l.batch)
+		}
+		l.batch.keys = append(l.batch.keys, key)
+		l.batch.results = append(l.batch.results, result)
+
+		if len(l.batch.keys) >= loaderMaxBatch {
+			batch := l.batch
+			l.batch = nil
+			batch.closed = true
+			go l.dispatch(ctx, batch)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-result.done:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (l *DataLoader[K, V]) dispatchAfterWait(ctx context.Context, batch *loaderBatch[K, V]) {
+	time.Sleep(loaderWait)
+
+	l.mu.Lock()
+	if batch.closed {
+		// The batch filled up and was dispatched early.
+		l.mu.Unlock()
+		return
+	}
+	batch.closed = true
+	if l.batch == batch {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	l.dispatch(ctx, batch)
+}
+
+func (l *DataLoader[K, V]) dispatch(ctx context.Context, batch *loaderBatch[K, V]) {
+	values, err := l.fetch(ctx, batch.keys)
+	for i, key := range batch.keys {
+		result := batch.results[i]
+		if err != nil {
+			result.err = err
+		} else if value, ok := values[key]; ok {
+			result.value = value
+		} else {
+			result.err = fmt.Errorf("no value found for key %v", key)
+		}
+		close(result.done)
+	}
+}
+
+// Loaders holds the DataLoaders for a single GraphQL operation.
+type Loaders struct {
+	CircuitByID *DataLoader[string, *entity.Circuit]
+}
+
+func NewLoaders(circuitService service.CircuitService) *Loaders {
+	return &Loaders{
+		CircuitByID: NewDataLoader(func(ctx context.Context, ids []string) (map[string]*entity.Circuit, error) {
+			circuits, err := circuitService.GetCircuitsByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[string]*entity.Circuit, len(circuits))
+			for _, circuit := range circuits {
+				byID[circuit.ID] = circuit
+			}
+			return byID, nil
+		}),
+	}
+}
+
+type loadersKey struct{}
+
+// WithLoaders attaches a fresh set of loaders to ctx. Call it once per operation
+// so cached values never outlive the request that loaded them.
+func WithLoaders(ctx context.Context, circuitService service.CircuitService) context.Context {
+	return context.WithValue(ctx, loadersKey{}, NewLoaders(circuitService))
+}
+
+// loaders returns the operation's loaders, falling back to an unshared set when
+// none were attached (e.g. when the schema is executed outside the HTTP server).
+func (r *Resolver) loaders(ctx context.Context) *Loaders {
+	if l, ok := ctx.Value(loadersKey{}).(*Loaders); ok {
+		return l
+	}
+	return NewLoaders(r.CircuitService)
+}
@@ -0,0 +1,10 @@
+package graph
+
+// optionalInt converts an optional GraphQL Int argument to the int the service layer expects.
+func optionalInt(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
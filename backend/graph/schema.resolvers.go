@@ -8,94 +8,355 @@ import (
 	"backend/internal/entity"
 	"context"
 	"fmt"
+	"strings"
 )
 
+// Circuit is the resolver for the circuit field.
+func (r *circuitNodeResolver) Circuit(ctx context.Context, obj *entity.CircuitNode) (*entity.Circuit, error) {
+	if obj.Circuit == nil || obj.Circuit.ID == "" {
+		return nil, fmt.Errorf("circuit node %s does not reference a circuit", obj.ID)
+	}
+	return r.loaders(ctx).CircuitByID.Load(ctx, obj.Circuit.ID)
+}
+
 // CreateCircuit is the resolver for the createCircuit field.
 func (r *mutationResolver) CreateCircuit(ctx context.Context, title string) (*entity.Circuit, error) {
-	return r.CircuitService.CreateCircuit(title)
+	return r.CircuitService.CreateCircuit(ctx, title)
 }
 
 // CreateInputNode is the resolver for the createInputNode field.
-func (r *mutationResolver) CreateInputNode(ctx context.Context, circuitID string, title *string) (*entity.InputNode, error) {
+func (r *mutationResolver) CreateInputNode(ctx context.Context, circuitID string, title *string, expectedVersion *int32) (*entity.InputNode, error) {
 	titleStr := ""
 	if title != nil {
 		titleStr = *title
 	}
-	return r.CircuitService.CreateInputNode(circuitID, titleStr)
+	return r.CircuitService.CreateInputNode(ctx, circuitID, titleStr, optionalInt(expectedVersion))
 }
 
 // CreateOutputNode is the resolver for the createOutputNode field.
-func (r *mutationResolver) CreateOutputNode(ctx context.Context, circuitID string, title *string) (*entity.OutputNode, error) {
+func (r *mutationResolver) CreateOutputNode(ctx context.Context, circuitID string, title *string, expectedVersion *int32) (*entity.OutputNode, error) {
 	titleStr := ""
 	if title != nil {
 		titleStr = *title
 	}
-	return r.CircuitService.CreateOutputNode(circuitID, titleStr)
+	return r.CircuitService.CreateOutputNode(ctx, circuitID, titleStr, optionalInt(expectedVersion))
 }
 
 // CreateAndNode is the resolver for the createAndNode field.
-func (r *mutationResolver) CreateAndNode(ctx context.Context, circuitID string) (*entity.AndNode, error) {
-	return r.CircuitService.CreateAndNode(circuitID)
+func (r *mutationResolver) CreateAndNode(ctx context.Context, circuitID string, expectedVersion *int32) (*entity.AndNode, error) {
+	return r.CircuitService.CreateAndNode(ctx, circuitID, optionalInt(expectedVersion))
 }
 
 // CreateOrNode is the resolver for the createOrNode field.
-func (r *mutationResolver) CreateOrNode(ctx context.Context, circuitID string) (*entity.OrNode, error) {
-	return r.CircuitService.CreateOrNode(circuitID)
+func (r *mutationResolver) CreateOrNode(ctx context.Context, circuitID string, expectedVersion *int32) (*entity.OrNode, error) {
+	return r.CircuitService.CreateOrNode(ctx, circuitID, optionalInt(expectedVersion))
 }
 
 // CreateNotNode is the resolver for the createNotNode field.
-func (r *mutationResolver) CreateNotNode(ctx context.Context, circuitID string) (*entity.NotNode, error) {
-	return r.CircuitService.CreateNotNode(circuitID)
+func (r *mutationResolver) CreateNotNode(ctx context.Context, circuitID string, expectedVersion *int32) (*entity.NotNode, error) {
+	return r.CircuitService.CreateNotNode(ctx, circuitID, optionalInt(expectedVersion))
+}
+
+// CreateTriStateNode is the resolver for the createTriStateNode field.
+func (r *mutationResolver) CreateTriStateNode(ctx context.Context, circuitID string, expectedVersion *int32) (*entity.TriStateNode, error) {
+	return r.CircuitService.CreateTriStateNode(ctx, circuitID, optionalInt(expectedVersion))
+}
+
+// CreateDFlipFlopNode is the resolver for the createDFlipFlopNode field.
+func (r *mutationResolver) CreateDFlipFlopNode(ctx context.Context, circuitID string, title *string, expectedVersion *int32) (*entity.DFlipFlopNode, error) {
+	titleStr := ""
+	if title != nil {
+		titleStr = *title
+	}
+	return r.CircuitService.CreateDFlipFlopNode(ctx, circuitID, titleStr, optionalInt(expectedVersion))
+}
+
+// CreateSRLatchNode is the resolver for the createSRLatchNode field.
+func (r *mutationResolver) CreateSRLatchNode(ctx context.Context, circuitID string, title *string, expectedVersion *int32) (*entity.SRLatchNode, error) {
+	titleStr := ""
+	if title != nil {
+		titleStr = *title
+	}
+	return r.CircuitService.CreateSRLatchNode(ctx, circuitID, titleStr, optionalInt(expectedVersion))
 }
 
 // CreateCircuitNode is the resolver for the createCircuitNode field.
-func (r *mutationResolver) CreateCircuitNode(ctx context.Context, circuitID string, referencedCircuitID string) (*entity.CircuitNode, error) {
-	return r.CircuitService.CreateCircuitNode(circuitID, referencedCircuitID)
+func (r *mutationResolver) CreateCircuitNode(ctx context.Context, circuitID string, referencedCircuitID string, expectedVersion *int32) (*entity.CircuitNode, error) {
+	return r.CircuitService.CreateCircuitNode(ctx, circuitID, referencedCircuitID, optionalInt(expectedVersion))
 }
 
 // CreateEdge is the resolver for the createEdge field.
-func (r *mutationResolver) CreateEdge(ctx context.Context, circuitID string, sourceNodeID string, targetNodeID string) (*entity.Edge, error) {
-	return r.CircuitService.CreateEdge(circuitID, sourceNodeID, targetNodeID)
+func (r *mutationResolver) CreateEdge(ctx context.Context, circuitID string, sourceNodeID string, targetNodeID string, sourcePort *string, targetPort *string, expectedVersion *int32) (*entity.Edge, error) {
+	sourcePortStr := ""
+	if sourcePort != nil {
+		sourcePortStr = *sourcePort
+	}
+	targetPortStr := ""
+	if targetPort != nil {
+		targetPortStr = *targetPort
+	}
+	return r.CircuitService.CreateEdge(ctx, circuitID, sourceNodeID, targetNodeID, sourcePortStr, targetPortStr, optionalInt(expectedVersion))
+}
+
+// RestoreCircuitRevision is the resolver for the restoreCircuitRevision field.
+func (r *mutationResolver) RestoreCircuitRevision(ctx context.Context, circuitID string, revision int32, expectedVersion *int32) (*entity.Circuit, error) {
+	return r.CircuitService.RestoreCircuitRevision(ctx, circuitID, int(revision), optionalInt(expectedVersion))
+}
+
+// Undo is the resolver for the undo field.
+func (r *mutationResolver) Undo(ctx context.Context, circuitID string, expectedVersion *int32) (*entity.Circuit, error) {
+	return r.CircuitService.Undo(ctx, circuitID, optionalInt(expectedVersion))
+}
+
+// Redo is the resolver for the redo field.
+func (r *mutationResolver) Redo(ctx context.Context, circuitID string, expectedVersion *int32) (*entity.Circuit, error) {
+	return r.CircuitService.Redo(ctx, circuitID, optionalInt(expectedVersion))
+}
+
+// SetLiveInputs is the resolver for the setLiveInputs field.
+func (r *mutationResolver) SetLiveInputs(ctx context.Context, sessionID string, inputs []*entity.InputNodeValue) (bool, error) {
+	if err := r.CircuitService.SetLiveInputs(ctx, sessionID, inputs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartSimulation is the resolver for the startSimulation field.
+func (r *mutationResolver) StartSimulation(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue) (*entity.SimulationStep, error) {
+	return r.CircuitService.StartSimulation(ctx, circuitID, inputs)
+}
+
+// SetSimulationInputs is the resolver for the setSimulationInputs field.
+func (r *mutationResolver) SetSimulationInputs(ctx context.Context, simulationID string, inputs []*entity.InputNodeValue) (*entity.SimulationStep, error) {
+	return r.CircuitService.SetSimulationInputs(ctx, simulationID, inputs)
+}
+
+// StepClock is the resolver for the stepClock field.
+func (r *mutationResolver) StepClock(ctx context.Context, simulationID string, cycles int32) ([]*entity.SimulationStep, error) {
+	return r.CircuitService.StepClock(ctx, simulationID, int(cycles))
+}
+
+// ResetSimulation is the resolver for the resetSimulation field.
+func (r *mutationResolver) ResetSimulation(ctx context.Context, simulationID string) (*entity.SimulationStep, error) {
+	return r.CircuitService.ResetSimulation(ctx, simulationID)
+}
+
+// StopSimulation is the resolver for the stopSimulation field.
+func (r *mutationResolver) StopSimulation(ctx context.Context, simulationID string) (bool, error) {
+	if err := r.CircuitService.StopSimulation(ctx, simulationID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddCircuitTest is the resolver for the addCircuitTest field.
+func (r *mutationResolver) AddCircuitTest(ctx context.Context, circuitID string, name string, inputs []*entity.InputNodeValue, expected []*entity.ExpectedOutput) (*entity.CircuitTest, error) {
+	return r.CircuitService.AddCircuitTest(ctx, circuitID, name, inputs, expected)
+}
+
+// DeleteCircuitTest is the resolver for the deleteCircuitTest field.
+func (r *mutationResolver) DeleteCircuitTest(ctx context.Context, circuitID string, testID string) (bool, error) {
+	if err := r.CircuitService.DeleteCircuitTest(ctx, circuitID, testID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RunCircuitTests is the resolver for the runCircuitTests field.
+func (r *mutationResolver) RunCircuitTests(ctx context.Context, circuitID string) (*entity.CircuitTestRun, error) {
+	return r.CircuitService.RunCircuitTests(ctx, circuitID)
+}
+
+// CreateExercise is the resolver for the createExercise field.
+func (r *mutationResolver) CreateExercise(ctx context.Context, title string, description *string, inputs []string, outputs []string, truthTable []*entity.TruthTableRow, referenceCircuitID *string, allowedGateTypes []entity.NodeType, maxGates *int32, maxDepth *int32) (*entity.Exercise, error) {
+	exercise := &entity.Exercise{
+		Title:            title,
+		Inputs:           inputs,
+		Outputs:          outputs,
+		TruthTable:       truthTable,
+		AllowedGateTypes: allowedGateTypes,
+		MaxGates:         maxGates,
+		MaxDepth:         maxDepth,
+	}
+	if description != nil {
+		exercise.Description = *description
+	}
+	if referenceCircuitID != nil {
+		exercise.ReferenceCircuitID = *referenceCircuitID
+	}
+	return r.CircuitService.CreateExercise(ctx, exercise)
+}
+
+// SubmitSolution is the resolver for the submitSolution field.
+func (r *mutationResolver) SubmitSolution(ctx context.Context, exerciseID string, circuitID string) (*entity.ExerciseSubmission, error) {
+	return r.CircuitService.SubmitSolution(ctx, exerciseID, circuitID)
 }
 
 // Circuits is the resolver for the circuits field.
 func (r *queryResolver) Circuits(ctx context.Context) ([]*entity.Circuit, error) {
-	return r.CircuitService.GetAllCircuits()
+	return r.CircuitService.GetAllCircuits(ctx)
 }
 
 // Circuit is the resolver for the circuit field.
-func (r *queryResolver) Circuit(ctx context.Context, id string) (*entity.Circuit, error) {
-	return r.CircuitService.GetCircuit(id)
+func (r *queryResolver) Circuit(ctx context.Context, id string, revision *int32) (*entity.Circuit, error) {
+	if revision != nil {
+		return r.CircuitService.GetCircuitAtRevision(ctx, id, int(*revision))
+	}
+	return r.CircuitService.GetCircuit(ctx, id)
+}
+
+// CircuitRevisions is the resolver for the circuitRevisions field.
+func (r *queryResolver) CircuitRevisions(ctx context.Context, id string) ([]*entity.CircuitRevision, error) {
+	return r.CircuitService.GetCircuitRevisions(ctx, id)
 }
 
 // EvaluateCircuit is the resolver for the evaluateCircuit field.
 func (r *queryResolver) EvaluateCircuit(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue) (*entity.EvaluationResult, error) {
-	circuit, err := r.CircuitService.GetCircuit(circuitID)
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	return r.CircuitService.EvaluateCircuit(ctx, circuit, inputs)
+}
+
+// EvaluateFixedPoint is the resolver for the evaluateFixedPoint field.
+func (r *queryResolver) EvaluateFixedPoint(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue, initialValues []*entity.NodeValue, maxIterations int32) (*entity.FixedPointResult, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	return r.CircuitService.EvaluateFixedPoint(ctx, circuit, inputs, initialValues, int(maxIterations))
+}
+
+// SimulateTiming is the resolver for the simulateTiming field.
+func (r *queryResolver) SimulateTiming(ctx context.Context, circuitID string, inputs []*entity.TimedInputValue, typeDelays []*entity.NodeTypeDelay, nodeDelays []*entity.NodeDelay, until *int32) (*entity.TimingResult, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	options := entity.TimingOptions{TypeDelays: typeDelays, NodeDelays: nodeDelays, Until: until}
+	return r.CircuitService.SimulateTiming(ctx, circuit, inputs, options)
+}
+
+// DetectHazards is the resolver for the detectHazards field.
+func (r *queryResolver) DetectHazards(ctx context.Context, circuitID string) (*entity.HazardReport, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	return r.CircuitService.DetectHazards(ctx, circuit)
+}
+
+// CheckEquivalence is the resolver for the checkEquivalence field.
+func (r *queryResolver) CheckEquivalence(ctx context.Context, circuitA string, circuitB string) (*entity.EquivalenceResult, error) {
+	a, err := r.CircuitService.GetCircuit(ctx, circuitA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	b, err := r.CircuitService.GetCircuit(ctx, circuitB)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get circuit: %w", err)
 	}
-	return r.CircuitService.EvaluateCircuit(circuit, inputs)
+	return r.CircuitService.CheckEquivalence(ctx, a, b)
 }
 
+// FindSatisfyingInputs is the resolver for the findSatisfyingInputs field.
+func (r *queryResolver) FindSatisfyingInputs(ctx context.Context, circuitID string, outputConstraints []*entity.ExpectedOutput) (*entity.SatisfiabilityResult, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	return r.CircuitService.FindSatisfyingInputs(ctx, circuit, outputConstraints)
+}
+
+// Vcd is the resolver for the vcd field.
+func (r *queryResolver) Vcd(ctx context.Context, circuitID string, vectors [][]*entity.InputNodeValue) (string, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get circuit: %w", err)
+	}
+	var vcd strings.Builder
+	if err := r.CircuitService.ExportVCD(ctx, circuit, vectors, &vcd); err != nil {
+		return "", err
+	}
+	return vcd.String(), nil
+}
+
+// Dimacs is the resolver for the dimacs field.
+func (r *queryResolver) Dimacs(ctx context.Context, circuitID string, assertOutputs []*entity.ExpectedOutput) (string, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get circuit: %w", err)
+	}
+	var dimacs strings.Builder
+	if err := r.CircuitService.ExportDIMACS(ctx, circuit, assertOutputs, &dimacs); err != nil {
+		return "", err
+	}
+	return dimacs.String(), nil
+}
+
+// RunStimulus is the resolver for the runStimulus field.
+func (r *queryResolver) RunStimulus(ctx context.Context, circuitID string, content string, format *entity.StimulusFormat, mode entity.StimulusMode) (*entity.StimulusResult, error) {
+	circuit, err := r.CircuitService.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	var formatValue entity.StimulusFormat
+	if format != nil {
+		formatValue = *format
+	}
+	return r.CircuitService.RunStimulus(ctx, circuit, formatValue, content, mode)
+}
+
+// CircuitTests is the resolver for the circuitTests field.
+func (r *queryResolver) CircuitTests(ctx context.Context, circuitID string) ([]*entity.CircuitTest, error) {
+	return r.CircuitService.GetCircuitTests(ctx, circuitID)
+}
+
+// CircuitTestRuns is the resolver for the circuitTestRuns field.
+func (r *queryResolver) CircuitTestRuns(ctx context.Context, circuitID string, limit int32) ([]*entity.CircuitTestRun, error) {
+	return r.CircuitService.GetCircuitTestRuns(ctx, circuitID, int(limit))
+}
+
+// Exercises is the resolver for the exercises field.
+func (r *queryResolver) Exercises(ctx context.Context) ([]*entity.Exercise, error) {
+	return r.CircuitService.GetAllExercises(ctx)
+}
+
+// Exercise is the resolver for the exercise field.
+func (r *queryResolver) Exercise(ctx context.Context, id string) (*entity.Exercise, error) {
+	return r.CircuitService.GetExercise(ctx, id)
+}
+
+// ExerciseSubmissions is the resolver for the exerciseSubmissions field.
+func (r *queryResolver) ExerciseSubmissions(ctx context.Context, exerciseID string, limit int32) ([]*entity.ExerciseSubmission, error) {
+	return r.CircuitService.GetExerciseSubmissions(ctx, exerciseID, int(limit))
+}
+
+// CircuitChanged is the resolver for the circuitChanged field.
+func (r *subscriptionResolver) CircuitChanged(ctx context.Context, circuitID string) (<-chan *entity.CircuitChangeEvent, error) {
+	return r.CircuitService.SubscribeCircuitChanges(ctx, circuitID)
+}
+
+// LiveEvaluation is the resolver for the liveEvaluation field.
+func (r *subscriptionResolver) LiveEvaluation(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue, sessionID *string) (<-chan *entity.EvaluationResult, error) {
+	// sessionID is deprecated and ignored; the service assigns one
+	return r.CircuitService.LiveEvaluation(ctx, circuitID, inputs)
+}
+
+// CircuitNode returns CircuitNodeResolver implementation.
+func (r *Resolver) CircuitNode() CircuitNodeResolver { return &circuitNodeResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type circuitNodeResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
-
-// !!! WARNING !!!
-// The code below was going to be deleted when updating resolvers. It has been copied here so you have
-// one last chance to move it out of harms way if you want. There are two reasons this happens:
-//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
-//    it when you're done.
-//  - You have helper methods in this file. Move them out to keep these resolver files clean.
-/*
-	func (r *circuitResolver) Title(ctx context.Context, obj *entity.Circuit) (string, error) {
-	panic(fmt.Errorf("not implemented: Title - title"))
-}
-func (r *Resolver) Circuit() CircuitResolver { return &circuitResolver{r} }
-type circuitResolver struct{ *Resolver }
-*/
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,186 @@
+package entity
+
+// bddRef refers to a node of a bdd. The terminals false and true are 0 and 1.
+type bddRef int32
+
+const (
+	bddFalse bddRef = 0
+	bddTrue  bddRef = 1
+)
+
+// bddNode tests variable level: low is followed when it is false, high when it is true.
+type bddNode struct {
+	level     int32
+	low, high bddRef
+}
+
+// bddCacheSize is the number of entries of a bdd's computed table, a power of two.
+const bddCacheSize = 1 << 16
+
+type bddOp struct {
+	op   byte
+	a, b bddRef
+}
+
+// bddCacheEntry is a slot of the computed table. Ops are never zero, so an empty
+// slot matches no op.
+type bddCacheEntry struct {
+	key bddOp
+	ref bddRef
+}
+
+// bdd is a reduced ordered binary decision diagram manager. Variables are tested in
+// the order of their levels. Once limit nodes exist, operations return bddFalse and
+// full is set, so callers check full before trusting a result.
+//
+// Results of operations are kept in a fixed-size computed table, where a result
+// overwrites whichever one had the same slot before it.
+type bdd struct {
+	nodes  []bddNode
+	unique map[bddNode]bddRef
+	cache  []bddCacheEntry
+	limit  int
+	full   bool
+}
+
+func newBDD(limit int) *bdd {
+	return &bdd{
+		// The terminals sort after every variable.
+		nodes:  []bddNode{{level: -1}, {level: -1}},
+		unique: make(map[bddNode]bddRef),
+		cache:  make([]bddCacheEntry, bddCacheSize),
+		limit:  limit,
+	}
+}
+
+// variable returns the function that is true when the variable of the level is.
+func (b *bdd) variable(level int) bddRef {
+	return b.node(int32(level), bddFalse, bddTrue)
+}
+
+func (b *bdd) node(level int32, low, high bddRef) bddRef {
+	if low == high {
+		return low
+	}
+	key := bddNode{level: level, low: low, high: high}
+	if ref, ok := b.unique[key]; ok {
+		return ref
+	}
+	if len(b.nodes) >= b.limit {
+		b.full = true
+		return bddFalse
+	}
+	ref := bddRef(len(b.nodes))
+	b.nodes = append(b.nodes, key)
+	b.unique[key] = ref
+	return ref
+}
+
+// level returns the variable tested by u, treating terminals as below every variable.
+func (b *bdd) level(u bddRef) int32 {
+	if u <= bddTrue {
+		return 1<<31 - 1
+	}
+	return b.nodes[u].level
+}
+
+func (b *bdd) constant(value bool) bddRef {
+	if value {
+		return bddTrue
+	}
+	return bddFalse
+}
+
+func (b *bdd) not(u bddRef) bddRef {
+	switch u {
+	case bddFalse:
+		return bddTrue
+	case bddTrue:
+		return bddFalse
+	}
+	return b.apply('!', u, u)
+}
+
+func (b *bdd) and(u, v bddRef) bddRef {
+	switch {
+	case u == bddFalse || v == bddFalse:
+		return bddFalse
+	case u == bddTrue:
+		return v
+	case v == bddTrue || u == v:
+		return u
+	}
+	return b.apply('&', min(u, v), max(u, v))
+}
+
+func (b *bdd) or(u, v bddRef) bddRef {
+	switch {
+	case u == bddTrue || v == bddTrue:
+		return bddTrue
+	case u == bddFalse:
+		return v
+	case v == bddFalse || u == v:
+		return u
+	}
+	return b.apply('|', min(u, v), max(u, v))
+}
+
+// apply combines two non-terminal functions by Shannon expansion on their top variable.
+func (b *bdd) apply(op byte, u, v bddRef) bddRef {
+	if b.full {
+		return bddFalse
+	}
+	key := bddOp{op: op, a: u, b: v}
+	entry := &b.cache[key.hash()&(bddCacheSize-1)]
+	if entry.key == key {
+		return entry.ref
+	}
+
+	level := min(b.level(u), b.level(v))
+	uLow, uHigh := b.cofactors(u, level)
+	vLow, vHigh := b.cofactors(v, level)
+	var low, high bddRef
+	switch op {
+	case '!':
+		low, high = b.not(uLow), b.not(uHigh)
+	case '&':
+		low, high = b.and(uLow, vLow), b.and(uHigh, vHigh)
+	default:
+		low, high = b.or(uLow, vLow), b.or(uHigh, vHigh)
+	}
+	ref := b.node(level, low, high)
+	if !b.full {
+		*entry = bddCacheEntry{key: key, ref: ref}
+	}
+	return ref
+}
+
+func (k bddOp) hash() uint32 {
+	h := uint32(k.a)*0x9e3779b1 ^ uint32(k.b)*0x85ebca77 ^ uint32(k.op)
+	return h ^ h>>16
+}
+
+// cofactors returns u with the variable of level set to false and to true.
+func (b *bdd) cofactors(u bddRef, level int32) (bddRef, bddRef) {
+	if b.level(u) != level {
+		return u, u
+	}
+	return b.nodes[u].low, b.nodes[u].high
+}
+
+// satisfy returns an assignment of the given number of variables for which u is true,
+// with variables u does not depend on set to false. u must not be bddFalse.
+func (b *bdd) satisfy(u bddRef, variables int) []bool {
+	assignment := make([]bool, variables)
+	for u > bddTrue {
+		node := b.nodes[u]
+		// In a reduced diagram every non-terminal node has a path to true.
+		if node.low != bddFalse {
+			u = node.low
+		} else {
+			assignment[node.level] = true
+			u = node.high
+		}
+	}
+	return assignment
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CircuitChangeKind identifies what happened to a circuit in a CircuitChangeEvent.
+type CircuitChangeKind string
+
+const (
+	NodeAdded   CircuitChangeKind = "NODE_ADDED"
+	NodeRemoved CircuitChangeKind = "NODE_REMOVED"
+	NodeUpdated CircuitChangeKind = "NODE_UPDATED"
+	EdgeAdded   CircuitChangeKind = "EDGE_ADDED"
+	EdgeRemoved CircuitChangeKind = "EDGE_REMOVED"
+	EdgeUpdated CircuitChangeKind = "EDGE_UPDATED"
+)
+
+func (k CircuitChangeKind) IsValid() bool {
+	switch k {
+	case NodeAdded, NodeRemoved, NodeUpdated, EdgeAdded, EdgeRemoved, EdgeUpdated:
+		return true
+	}
+	return false
+}
+
+func (k CircuitChangeKind) String() string {
+	return string(k)
+}
+
+func (k *CircuitChangeKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*k = CircuitChangeKind(str)
+	if !k.IsValid() {
+		return fmt.Errorf("%s is not a valid CircuitChangeKind", str)
+	}
+	return nil
+}
+
+func (k CircuitChangeKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(k.String()))
+}
+
+// CircuitChangeEvent describes a single change to a circuit's nodes or edges.
+// Node is set for NODE_* kinds and Edge for EDGE_* kinds. Version is the circuit
+// version after the change.
+type CircuitChangeEvent struct {
+	CircuitID string            `json:"circuitID"`
+	Kind      CircuitChangeKind `json:"kind"`
+	Version   int32             `json:"version"`
+	Node      Node              `json:"node"`
+	Edge      *Edge             `json:"edge"`
+}
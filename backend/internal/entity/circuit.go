@@ -3,6 +3,7 @@ package entity
 type Circuit struct {
 	ID    string `json:"id"`
 	Title string `json:"title"`
+	Version int32 `json:"version"`
 	Nodes []Node `json:"nodes"`
 	Edges []*Edge `json:"edges"`
 }
\ No newline at end of file
@@ -0,0 +1,142 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ExpectedOutput is the value an output, addressed by title, should have. A nil Value
+// is a don't-care.
+type ExpectedOutput struct {
+	Title string      `json:"title"`
+	Value *LogicValue `json:"value"`
+}
+
+// CircuitTest is a named test case stored with a circuit: input values and the
+// outputs they should produce. Inputs not given are unknown.
+type CircuitTest struct {
+	ID        string            `json:"id"`
+	CircuitID string            `json:"circuitID"`
+	Name      string            `json:"name"`
+	Inputs    []*InputNodeValue `json:"inputs"`
+	Expected  []*ExpectedOutput `json:"expected"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// CircuitTestResult is the outcome of one test case.
+type CircuitTestResult struct {
+	TestID       string            `json:"testID"`
+	Name         string            `json:"name"`
+	Passed       bool              `json:"passed"`
+	Outputs      []*NodeOutput     `json:"outputs"`
+	NamedOutputs NamedOutputs      `json:"namedOutputs"`
+	Mismatches   []*OutputMismatch `json:"mismatches"`
+	// Error is set when the test could not be evaluated.
+	Error string `json:"error"`
+}
+
+// CircuitTestRun is one run of a circuit's test cases, kept as history.
+type CircuitTestRun struct {
+	ID             string               `json:"id"`
+	CircuitID      string               `json:"circuitID"`
+	CircuitVersion int32                `json:"circuitVersion"`
+	RanAt          time.Time            `json:"ranAt"`
+	Passed         bool                 `json:"passed"`
+	Total          int32                `json:"total"`
+	Failed         int32                `json:"failed"`
+	Results        []*CircuitTestResult `json:"results"`
+}
+
+// CheckTest reports an error if test does not fit the circuit: it must be named,
+// and its inputs and expected outputs must name inputs and titled outputs of c.
+func (c *Circuit) CheckTest(test *CircuitTest) error {
+	if test == nil {
+		return fmt.Errorf("test cannot be nil")
+	}
+	if test.Name == "" {
+		return fmt.Errorf("test name cannot be empty")
+	}
+
+	graph := c.newEvaluationGraph()
+	for _, input := range test.Inputs {
+		if _, err := graph.inputNodeID(input); err != nil {
+			return err
+		}
+	}
+
+	outputs := make(map[string]int)
+	for _, node := range c.Nodes {
+		if output, ok := node.(*OutputNode); ok && output.Title != "" {
+			outputs[output.Title]++
+		}
+	}
+	for _, expected := range test.Expected {
+		switch outputs[expected.Title] {
+		case 0:
+			return fmt.Errorf("no OutputNode titled '%s'", expected.Title)
+		case 1:
+		default:
+			return fmt.Errorf("several OutputNodes are titled '%s'", expected.Title)
+		}
+		if expected.Value != nil && !expected.Value.IsValid() {
+			return fmt.Errorf("invalid expected value for output '%s'", expected.Title)
+		}
+	}
+	return nil
+}
+
+// RunTests evaluates every test case and compares the outputs with the expected
+// values. A test that cannot be evaluated, e.g. because the circuit has changed
+// since it was written, fails with an error.
+func (c *Circuit) RunTests(ctx context.Context, tests []*CircuitTest) (*CircuitTestRun, error) {
+	run := &CircuitTestRun{
+		CircuitID:      c.ID,
+		CircuitVersion: c.Version,
+		Passed:         true,
+		Total:          int32(len(tests)),
+		Results:        make([]*CircuitTestResult, len(tests)),
+	}
+
+	validated, validateErr := c.Validate()
+	for i, test := range tests {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result := &CircuitTestResult{TestID: test.ID, Name: test.Name, Outputs: []*NodeOutput{}, Mismatches: []*OutputMismatch{}}
+		err := validateErr
+		if err == nil {
+			err = c.CheckTest(test)
+		}
+		if err == nil {
+			var evaluation *EvaluationResult
+			evaluation, err = validated.Evaluate(ctx, test.Inputs)
+			if err == nil {
+				expected := make(map[string]LogicValue)
+				for _, output := range test.Expected {
+					if output.Value != nil {
+						expected[output.Title] = *output.Value
+					}
+				}
+				result.Outputs = evaluation.Outputs
+				result.NamedOutputs = evaluation.NamedOutputs
+				result.Mismatches = DiffOutputs(evaluation.Outputs, expected)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = err.Error()
+		}
+
+		result.Passed = result.Error == "" && len(result.Mismatches) == 0
+		if !result.Passed {
+			run.Passed = false
+			run.Failed++
+		}
+		run.Results[i] = result
+	}
+	return run, nil
+}
@@ -0,0 +1,258 @@
+package entity
+
+import (
+	"backend/internal/sat"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CircuitCNF is a Tseitin encoding of a combinational circuit. The value of every
+// node is encoded by four literals, one for each logic value, exactly one of which
+// is true. Gates that can only be true or false share a single variable for both.
+type CircuitCNF struct {
+	CNF *sat.CNF
+	// Inputs lists the titled inputs in sorted order; InputVars holds their variables.
+	Inputs    []string
+	InputVars []sat.Lit
+	circuit   *Circuit
+	// truth is the variable fixed to true; its negation stands for false.
+	truth sat.Lit
+	// nodes and values hold the literals of every node, for describing the encoding.
+	nodes   []Node
+	values  []logicPlanes[sat.Lit]
+	outputs map[string]logicPlanes[sat.Lit]
+	// asserted records the output values required by AssertOutputs, in order.
+	asserted []*ExpectedOutput
+}
+
+// EncodeCNF encodes a combinational circuit as CNF over the values of its titled
+// inputs. Untitled inputs are unknown. Nested circuits must be flattened first.
+func (c *Circuit) EncodeCNF(ctx context.Context) (*CircuitCNF, error) {
+	lg, err := c.combinationalLevels()
+	if err != nil {
+		return nil, err
+	}
+
+	algebra := newCNFAlgebra()
+	encoding := &CircuitCNF{CNF: algebra.cnf, Inputs: ioTitles(c, true), circuit: c, truth: algebra.truth}
+	variables := make(map[string]sat.Lit, len(encoding.Inputs))
+	for _, title := range encoding.Inputs {
+		variables[title] = algebra.cnf.NewVar()
+		encoding.InputVars = append(encoding.InputVars, variables[title])
+	}
+	if encoding.values, err = evaluatePlanes[sat.Lit](ctx, algebra, lg, variables); err != nil {
+		return nil, err
+	}
+	encoding.nodes = lg.nodes
+	encoding.outputs = outputPlanes(lg, encoding.values)
+	return encoding, nil
+}
+
+// AssertOutputs adds clauses requiring outputs, addressed by title, to have the
+// given values. Constraints with a nil value add no clause, but must still name an
+// output.
+func (e *CircuitCNF) AssertOutputs(constraints []*ExpectedOutput) error {
+	constrained := make(map[string]bool)
+	for _, constraint := range constraints {
+		if constrained[constraint.Title] {
+			return fmt.Errorf("output '%s' is constrained more than once", constraint.Title)
+		}
+		constrained[constraint.Title] = true
+		if _, ok := e.outputs[constraint.Title]; !ok {
+			return fmt.Errorf("no OutputNode titled '%s'", constraint.Title)
+		}
+		if constraint.Value == nil {
+			continue
+		}
+		if err := e.assert(constraint.Title, *constraint.Value); err != nil {
+			return err
+		}
+		e.asserted = append(e.asserted, constraint)
+	}
+	return nil
+}
+
+// assert adds a clause requiring the titled output to have the given value.
+func (e *CircuitCNF) assert(title string, value LogicValue) error {
+	planes, ok := e.outputs[title]
+	if !ok {
+		return fmt.Errorf("no OutputNode titled '%s'", title)
+	}
+	switch value {
+	case LogicTrue:
+		e.CNF.Add(planes.t)
+	case LogicFalse:
+		e.CNF.Add(planes.f)
+	case LogicUnknown:
+		e.CNF.Add(planes.x)
+	case LogicHighZ:
+		e.CNF.Add(planes.z)
+	default:
+		return fmt.Errorf("invalid value for output '%s'", title)
+	}
+	return nil
+}
+
+// SatisfiabilityResult is the outcome of FindSatisfyingInputs.
+type SatisfiabilityResult struct {
+	Satisfiable bool `json:"satisfiable"`
+	// Inputs assigns every titled input, in sorted order; nil when unsatisfiable.
+	Inputs []*InputNodeValue `json:"inputs"`
+	// Outputs and NamedOutputs are the circuit's outputs for Inputs.
+	Outputs      []*NodeOutput `json:"outputs"`
+	NamedOutputs NamedOutputs  `json:"namedOutputs"`
+	// Variables and Clauses measure the encoding given to the solver.
+	Variables int32 `json:"variables"`
+	Clauses   int32 `json:"clauses"`
+}
+
+// FindSatisfyingInputs searches for values of the titled inputs that give the
+// constrained outputs their values, or proves that none exist. Constraints with a
+// nil value are ignored.
+func (c *Circuit) FindSatisfyingInputs(ctx context.Context, constraints []*ExpectedOutput) (*SatisfiabilityResult, error) {
+	encoding, err := c.EncodeCNF(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := encoding.AssertOutputs(constraints); err != nil {
+		return nil, err
+	}
+
+	result := &SatisfiabilityResult{
+		Variables: int32(encoding.CNF.NumVars),
+		Clauses:   int32(len(encoding.CNF.Clauses)),
+		Outputs:   []*NodeOutput{},
+	}
+	model, err := sat.Solve(ctx, encoding.CNF)
+	if errors.Is(err, sat.ErrUndecided) {
+		return nil, fmt.Errorf("could not decide whether the outputs can take these values: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if model == nil {
+		return result, nil
+	}
+
+	result.Satisfiable = true
+	result.Inputs = make([]*InputNodeValue, len(encoding.Inputs))
+	for i, title := range encoding.Inputs {
+		value := model[encoding.InputVars[i]]
+		result.Inputs[i] = &InputNodeValue{Title: title, Value: &value}
+	}
+	evaluation, err := c.EvaluateCircuit(ctx, result.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate circuit: %w", err)
+	}
+	result.Outputs = evaluation.Outputs
+	result.NamedOutputs = evaluation.NamedOutputs
+	return result, nil
+}
+
+// WriteDIMACS writes the encoding in DIMACS CNF format. Comment lines list, for
+// every node, the literals that are true exactly when the node is TRUE, FALSE,
+// UNKNOWN or HIGH_Z; values a node can never take are left out. Variables that
+// appear in no such list are auxiliary.
+func (e *CircuitCNF) WriteDIMACS(w io.Writer) error {
+	comments := []string{
+		fmt.Sprintf("Tseitin encoding of circuit %s %s", e.circuit.ID, strconv.Quote(e.circuit.Title)),
+		"node <id> <type> <title> followed by the literal for each value the node can take",
+		fmt.Sprintf("variable %d is constant true", e.truth),
+	}
+	for i, node := range e.nodes {
+		nodeType := string(NodeTypeOf(node))
+		if nodeType == "" {
+			nodeType = "WIRE"
+		}
+		line := fmt.Sprintf("node %s %s %s", node.GetID(), nodeType, strconv.Quote(NodeTitle(node)))
+		value := e.values[i]
+		for _, plane := range []struct {
+			value LogicValue
+			lit   sat.Lit
+		}{{LogicTrue, value.t}, {LogicFalse, value.f}, {LogicUnknown, value.x}, {LogicHighZ, value.z}} {
+			if plane.lit != e.truth.Not() {
+				line += fmt.Sprintf(" %s=%d", plane.value, plane.lit)
+			}
+		}
+		comments = append(comments, line)
+	}
+	for _, constraint := range e.asserted {
+		comments = append(comments, fmt.Sprintf("asserted output %s = %s", strconv.Quote(constraint.Title), *constraint.Value))
+	}
+	return e.CNF.WriteDIMACS(w, comments)
+}
+
+// combinationalLevels validates a circuit without state nodes and returns its levels.
+func (c *Circuit) combinationalLevels() (*levelGraph, error) {
+	for _, node := range c.Nodes {
+		if isStateNode(node) {
+			return nil, fmt.Errorf("circuit %s is not combinational: node %s holds state", c.ID, node.GetID())
+		}
+	}
+	validated, err := c.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("circuit %s: %w", c.ID, err)
+	}
+	// Inputs and outputs are matched by title
+	if err := c.CheckUniqueTitles(); err != nil {
+		return nil, fmt.Errorf("circuit %s: %w", c.ID, err)
+	}
+	return newLevelGraph(validated.graph), nil
+}
+
+// cnfAlgebra builds a Tseitin encoding as a boolAlgebra over literals. Every and of
+// two literals that constant folding does not decide gets a variable defined by three
+// clauses, and structural hashing shares equal ands, so or and not need no variables
+// of their own.
+type cnfAlgebra struct {
+	cnf *sat.CNF
+	// truth is a variable fixed to true that stands for the constants.
+	truth sat.Lit
+	ands  map[[2]sat.Lit]sat.Lit
+}
+
+func newCNFAlgebra() *cnfAlgebra {
+	cnf := &sat.CNF{}
+	truth := cnf.NewVar()
+	cnf.Add(truth)
+	return &cnfAlgebra{cnf: cnf, truth: truth, ands: make(map[[2]sat.Lit]sat.Lit)}
+}
+
+func (a *cnfAlgebra) constant(value bool) sat.Lit {
+	if value {
+		return a.truth
+	}
+	return a.truth.Not()
+}
+
+func (a *cnfAlgebra) and(x, y sat.Lit) sat.Lit {
+	switch {
+	case x == a.truth.Not() || y == a.truth.Not() || x == y.Not():
+		return a.truth.Not()
+	case x == a.truth:
+		return y
+	case y == a.truth || x == y:
+		return x
+	}
+	key := [2]sat.Lit{min(x, y), max(x, y)}
+	if v, ok := a.ands[key]; ok {
+		return v
+	}
+	v := a.cnf.NewVar()
+	a.cnf.Add(v.Not(), x)
+	a.cnf.Add(v.Not(), y)
+	a.cnf.Add(v, x.Not(), y.Not())
+	a.ands[key] = v
+	return v
+}
+
+func (a *cnfAlgebra) or(x, y sat.Lit) sat.Lit {
+	return a.and(x.Not(), y.Not()).Not()
+}
+
+func (a *cnfAlgebra) not(x sat.Lit) sat.Lit {
+	return x.Not()
+}
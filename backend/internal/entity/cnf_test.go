@@ -0,0 +1,154 @@
+package entity
+
+import (
+	"backend/internal/sat"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// triStateCircuit drives outputs through tri-state buffers, so that together they
+// take every logic value: Y is a multiplexer of A and B selected by S, Z is A
+// enabled by S (HIGH_Z otherwise), and W is a bus that both A and B drive when S
+// is true, UNKNOWN when they disagree.
+func triStateCircuit() *Circuit {
+	c := &Circuit{ID: "tristate", Nodes: []Node{
+		&InputNode{ID: "a", Title: "A"},
+		&InputNode{ID: "b", Title: "B"},
+		&InputNode{ID: "s", Title: "S"},
+		&NotNode{ID: "ns"},
+		&TriStateNode{ID: "ta"},
+		&TriStateNode{ID: "tb"},
+		&TriStateNode{ID: "tc"},
+		&OutputNode{ID: "y", Title: "Y"},
+		&OutputNode{ID: "z", Title: "Z"},
+		&OutputNode{ID: "w", Title: "W"},
+	}}
+	for i, edge := range [][3]string{
+		{"s", "ns", ""},
+		{"a", "ta", PortData}, {"s", "ta", PortEnable},
+		{"b", "tb", PortData}, {"ns", "tb", PortEnable},
+		{"b", "tc", PortData}, {"s", "tc", PortEnable},
+		{"ta", "y", ""}, {"tb", "y", ""},
+		{"ta", "z", ""},
+		{"ta", "w", ""}, {"tc", "w", ""},
+	} {
+		c.Edges = append(c.Edges, &Edge{ID: fmt.Sprint(i), SourceNodeID: edge[0], TargetNodeID: edge[1], TargetPort: edge[2]})
+	}
+	return c
+}
+
+var logicValues = []LogicValue{LogicTrue, LogicFalse, LogicUnknown, LogicHighZ}
+
+func TestEncodeCNFMatchesEvaluation(t *testing.T) {
+	ctx := context.Background()
+	c := triStateCircuit()
+
+	for _, inputs := range allInputs([]string{"A", "B", "S"}) {
+		encoding, err := c.EncodeCNF(ctx)
+		if err != nil {
+			t.Fatalf("EncodeCNF() error = %v", err)
+		}
+		for i, title := range encoding.Inputs {
+			for _, input := range inputs {
+				if input.Title == title && *input.Value {
+					encoding.CNF.Add(encoding.InputVars[i])
+				} else if input.Title == title {
+					encoding.CNF.Add(encoding.InputVars[i].Not())
+				}
+			}
+		}
+		model, err := sat.Solve(ctx, encoding.CNF)
+		if err != nil || model == nil {
+			t.Fatalf("Solve() = %v, %v; want a model", model, err)
+		}
+
+		evaluation, err := c.EvaluateCircuit(ctx, inputs)
+		if err != nil {
+			t.Fatalf("EvaluateCircuit() error = %v", err)
+		}
+		holds := func(l sat.Lit) bool { return model[l.Var()] == (l > 0) }
+		for title, want := range evaluation.NamedOutputs {
+			planes := encoding.outputs[title]
+			var got []LogicValue
+			for j, l := range []sat.Lit{planes.t, planes.f, planes.x, planes.z} {
+				if holds(l) {
+					got = append(got, logicValues[j])
+				}
+			}
+			if len(got) != 1 || got[0] != want {
+				t.Errorf("inputs %s: output %s encoded as %v, evaluated to %s", inputsString(inputs), title, got, want)
+			}
+		}
+	}
+}
+
+func TestFindSatisfyingInputsMatchesEvaluation(t *testing.T) {
+	ctx := context.Background()
+	c := triStateCircuit()
+
+	// Record which output values some input assignment produces.
+	reachable := make(map[[3]LogicValue]bool)
+	for _, inputs := range allInputs([]string{"A", "B", "S"}) {
+		evaluation, err := c.EvaluateCircuit(ctx, inputs)
+		if err != nil {
+			t.Fatalf("EvaluateCircuit() error = %v", err)
+		}
+		reachable[[3]LogicValue{evaluation.NamedOutputs["Y"], evaluation.NamedOutputs["Z"], evaluation.NamedOutputs["W"]}] = true
+	}
+
+	for _, y := range logicValues {
+		for _, z := range logicValues {
+			for _, w := range logicValues {
+				y, z, w := y, z, w
+				constraints := []*ExpectedOutput{{Title: "Y", Value: &y}, {Title: "Z", Value: &z}, {Title: "W", Value: &w}}
+				result, err := c.FindSatisfyingInputs(ctx, constraints)
+				if err != nil {
+					t.Fatalf("FindSatisfyingInputs() error = %v", err)
+				}
+				want := reachable[[3]LogicValue{y, z, w}]
+				if result.Satisfiable != want {
+					t.Fatalf("Y=%s Z=%s W=%s: satisfiable = %v, want %v", y, z, w, result.Satisfiable, want)
+				}
+				if !result.Satisfiable {
+					continue
+				}
+				outputs := result.NamedOutputs
+				if outputs["Y"] != y || outputs["Z"] != z || outputs["W"] != w {
+					t.Fatalf("Y=%s Z=%s W=%s: inputs %s evaluate to %v", y, z, w, inputsString(result.Inputs), outputs)
+				}
+			}
+		}
+	}
+}
+
+func TestFindSatisfyingInputsRejectsInvalidConstraints(t *testing.T) {
+	ctx := context.Background()
+	value := LogicTrue
+	sequential := triStateCircuit()
+	sequential.Nodes = append(sequential.Nodes, &DFlipFlopNode{ID: "ff"})
+
+	tests := []struct {
+		name        string
+		circuit     *Circuit
+		constraints []*ExpectedOutput
+	}{
+		{"unknown output", triStateCircuit(), []*ExpectedOutput{{Title: "Q", Value: &value}}},
+		{"unknown output without a value", triStateCircuit(), []*ExpectedOutput{{Title: "Q"}}},
+		{"duplicate output", triStateCircuit(), []*ExpectedOutput{{Title: "Y", Value: &value}, {Title: "Y"}}},
+		{"state node", sequential, nil},
+	}
+	for _, tt := range tests {
+		if _, err := tt.circuit.FindSatisfyingInputs(ctx, tt.constraints); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func inputsString(inputs []*InputNodeValue) string {
+	s := ""
+	for _, input := range inputs {
+		s += fmt.Sprintf("%s=%v ", input.Title, *input.Value)
+	}
+	return s
+}
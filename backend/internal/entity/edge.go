@@ -1,7 +1,99 @@
 package entity
 
+import "fmt"
+
 type Edge struct {
 	ID           string `json:"id"`
 	SourceNodeID string `json:"sourceNodeID"`
 	TargetNodeID string `json:"targetNodeID"`
-}
\ No newline at end of file
+	// SourcePort names the output of a CircuitNode the edge is driven by: the
+	// title of an OutputNode of the referenced circuit.
+	SourcePort string `json:"sourcePort"`
+	// TargetPort names the input of the target node the edge feeds, for nodes
+	// with distinct inputs such as TriStateNode. Edges into a CircuitNode name
+	// the title of an InputNode of the referenced circuit.
+	TargetPort string `json:"targetPort"`
+}
+
+// Input ports of nodes with distinct inputs. Edges into other nodes leave TargetPort
+// empty, except edges into a CircuitNode.
+const (
+	// TriStateNode
+	PortData   = "data"
+	PortEnable = "enable"
+	// SRLatchNode
+	PortSet   = "set"
+	PortReset = "reset"
+)
+
+// ValidateTargetPort reports an error if port is not an input port of node.
+func ValidateTargetPort(node Node, port string) error {
+	var ports []string
+	switch node.(type) {
+	case *TriStateNode:
+		ports = []string{PortData, PortEnable}
+	case *SRLatchNode:
+		ports = []string{PortSet, PortReset}
+	case *CircuitNode:
+		// Checked against the referenced circuit (see CircuitPort).
+		return nil
+	default:
+		if port == "" {
+			return nil
+		}
+		return fmt.Errorf("node %s has no input port '%s'", node.GetID(), port)
+	}
+
+	for _, p := range ports {
+		if p == port {
+			return nil
+		}
+	}
+	return fmt.Errorf("node %s requires one of the input ports %v, got '%s'", node.GetID(), ports, port)
+}
+
+// ValidateSourcePort reports an error if node cannot drive an edge through port.
+// Only CircuitNodes have several outputs.
+func ValidateSourcePort(node Node, port string) error {
+	if _, ok := node.(*CircuitNode); ok || port == "" {
+		return nil
+	}
+	return fmt.Errorf("node %s has no output port '%s'", node.GetID(), port)
+}
+
+// CircuitPort returns the node of c an edge port of a CircuitNode referencing c
+// connects to: the InputNode (if input is set) or OutputNode titled port. An empty
+// port is allowed when c has exactly one such node.
+func CircuitPort(c *Circuit, port string, input bool) (Node, error) {
+	kind := "output"
+	if input {
+		kind = "input"
+	}
+
+	var candidates []Node
+	for _, node := range c.Nodes {
+		switch n := node.(type) {
+		case *InputNode:
+			if input && (port == "" || n.Title == port) {
+				candidates = append(candidates, n)
+			}
+		case *OutputNode:
+			if !input && (port == "" || n.Title == port) {
+				candidates = append(candidates, n)
+			}
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case port == "" && len(candidates) == 0:
+		return nil, fmt.Errorf("circuit %s has no %s", c.ID, kind)
+	case port == "":
+		return nil, fmt.Errorf("circuit %s has %d %ss, so the port must name one by title", c.ID, len(candidates), kind)
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("circuit %s has no %s titled '%s'", c.ID, kind, port)
+	default:
+		return nil, fmt.Errorf("circuit %s has several %ss titled '%s'", c.ID, kind, port)
+	}
+}
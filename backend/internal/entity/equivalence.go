@@ -0,0 +1,470 @@
+package entity
+
+import (
+	"backend/internal/sat"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxExhaustiveInputs is the largest number of inputs CheckEquivalence compares by
+	// simulating every combination; larger circuits are compared symbolically.
+	maxExhaustiveInputs = 20
+	// maxBDDNodes bounds the decision diagrams built for symbolic comparison.
+	maxBDDNodes = 1 << 18
+)
+
+// EquivalenceMethod is how CheckEquivalence compared two circuits.
+type EquivalenceMethod string
+
+const (
+	// EquivalenceExhaustive simulates every input combination, 64 at a time.
+	EquivalenceExhaustive EquivalenceMethod = "EXHAUSTIVE"
+	// EquivalenceBDD compares binary decision diagrams of the outputs.
+	EquivalenceBDD EquivalenceMethod = "BDD"
+	// EquivalenceSAT searches for a differing assignment with a SAT solver, for
+	// circuits whose decision diagrams grow too large.
+	EquivalenceSAT EquivalenceMethod = "SAT"
+)
+
+func (m EquivalenceMethod) IsValid() bool {
+	switch m {
+	case EquivalenceExhaustive, EquivalenceBDD, EquivalenceSAT:
+		return true
+	}
+	return false
+}
+
+func (m EquivalenceMethod) String() string {
+	return string(m)
+}
+
+func (m *EquivalenceMethod) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*m = EquivalenceMethod(str)
+	if !m.IsValid() {
+		return fmt.Errorf("%s is not a valid EquivalenceMethod", str)
+	}
+	return nil
+}
+
+func (m EquivalenceMethod) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(m.String()))
+}
+
+// OutputDifference is an output on which two circuits disagree for a counterexample.
+type OutputDifference struct {
+	Title string     `json:"title"`
+	A     LogicValue `json:"a"`
+	B     LogicValue `json:"b"`
+}
+
+// EquivalenceResult is the outcome of CheckEquivalence.
+type EquivalenceResult struct {
+	Equivalent bool              `json:"equivalent"`
+	Method     EquivalenceMethod `json:"method"`
+	// Inputs and Outputs are the titles that were compared, in sorted order.
+	Inputs  []string `json:"inputs"`
+	Outputs []string `json:"outputs"`
+	// Counterexample assigns every compared input, by title, such that the circuits
+	// differ; it is nil when they are equivalent.
+	Counterexample []*InputNodeValue   `json:"counterexample"`
+	Differences    []*OutputDifference `json:"differences"`
+}
+
+// CheckEquivalence decides whether two combinational circuits compute the same
+// functions. Inputs and outputs are matched by title; both circuits must have the
+// same titled outputs, while an input only one circuit has is simply one the other
+// does not depend on. Untitled inputs stay unknown. Outputs are compared with all
+// four logic values, so an output that is unknown or high impedance in one circuit
+// must be so in the other. Nested circuits must be flattened first.
+func CheckEquivalence(ctx context.Context, a, b *Circuit) (*EquivalenceResult, error) {
+	var graphs [2]*levelGraph
+	for i, c := range []*Circuit{a, b} {
+		var err error
+		if graphs[i], err = c.combinationalLevels(); err != nil {
+			return nil, err
+		}
+	}
+
+	inputs := mergeTitles(ioTitles(a, true), ioTitles(b, true))
+	outputsA, outputsB := ioTitles(a, false), ioTitles(b, false)
+	var differences []string
+	if onlyA := missingTitles(outputsA, outputsB); len(onlyA) > 0 {
+		differences = append(differences, fmt.Sprintf("only %s has %s", a.ID, strings.Join(onlyA, ", ")))
+	}
+	if onlyB := missingTitles(outputsB, outputsA); len(onlyB) > 0 {
+		differences = append(differences, fmt.Sprintf("only %s has %s", b.ID, strings.Join(onlyB, ", ")))
+	}
+	if len(differences) > 0 {
+		return nil, fmt.Errorf("circuits have different outputs: %s", strings.Join(differences, "; "))
+	}
+	if len(outputsA) == 0 {
+		return nil, fmt.Errorf("circuits have no titled outputs to compare")
+	}
+
+	result := &EquivalenceResult{Inputs: inputs, Outputs: outputsA, Differences: []*OutputDifference{}}
+	var assignment []bool
+	var err error
+	if len(inputs) <= maxExhaustiveInputs {
+		result.Method = EquivalenceExhaustive
+		assignment, err = exhaustiveCounterexample(ctx, graphs, inputs, outputsA)
+	} else {
+		var complete bool
+		result.Method = EquivalenceBDD
+		assignment, complete, err = bddCounterexample(ctx, graphs, inputs, outputsA)
+		if err == nil && !complete {
+			result.Method = EquivalenceSAT
+			assignment, err = satCounterexample(ctx, graphs, inputs, outputsA)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if assignment == nil {
+		result.Equivalent = true
+		return result, nil
+	}
+
+	result.Counterexample = make([]*InputNodeValue, len(inputs))
+	for i, title := range inputs {
+		value := assignment[i]
+		result.Counterexample[i] = &InputNodeValue{Title: title, Value: &value}
+	}
+	if result.Differences, err = diffCircuits(ctx, a, b, result.Counterexample); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// diffCircuits evaluates both circuits for the inputs, each getting the ones it has,
+// and lists the outputs on which they disagree.
+func diffCircuits(ctx context.Context, a, b *Circuit, inputs []*InputNodeValue) ([]*OutputDifference, error) {
+	var outputs [2]NamedOutputs
+	for i, c := range []*Circuit{a, b} {
+		titles := ioTitles(c, true)
+		var own []*InputNodeValue
+		for _, input := range inputs {
+			if containsString(titles, input.Title) {
+				own = append(own, input)
+			}
+		}
+		evaluation, err := c.EvaluateCircuit(ctx, own)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate circuit %s: %w", c.ID, err)
+		}
+		outputs[i] = make(NamedOutputs)
+		for _, output := range evaluation.Outputs {
+			outputs[i][output.Title] = output.State
+		}
+	}
+
+	differences := []*OutputDifference{}
+	for _, title := range ioTitles(a, false) {
+		if outputs[0][title] != outputs[1][title] {
+			differences = append(differences, &OutputDifference{Title: title, A: outputs[0][title], B: outputs[1][title]})
+		}
+	}
+	return differences, nil
+}
+
+// exhaustiveCounterexample simulates both circuits for every combination of inputs,
+// 64 combinations per machine word, and returns the first on which they differ.
+func exhaustiveCounterexample(ctx context.Context, graphs [2]*levelGraph, inputs, outputs []string) ([]bool, error) {
+	var algebra wordAlgebra
+	combinations := uint64(1) << len(inputs)
+	for word := uint64(0); word*64 < combinations; word++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		variables := make(map[string]uint64, len(inputs))
+		for i, title := range inputs {
+			variables[title] = wordPattern(word, i)
+		}
+		valid := ^uint64(0)
+		if combinations < 64 {
+			valid = 1<<combinations - 1
+		}
+
+		differ, err := differingCombinations[uint64](ctx, algebra, graphs, variables, outputs)
+		if err != nil {
+			return nil, err
+		}
+		if differ &= valid; differ != 0 {
+			combination := word*64 + uint64(bits.TrailingZeros64(differ))
+			assignment := make([]bool, len(inputs))
+			for i := range assignment {
+				assignment[i] = combination&(1<<i) != 0
+			}
+			return assignment, nil
+		}
+	}
+	return nil, nil
+}
+
+// wordPattern returns the values of input i in the 64 combinations of word, where
+// bit i of a combination's number is the value of input i.
+func wordPattern(word uint64, i int) uint64 {
+	patterns := [6]uint64{
+		0xAAAAAAAAAAAAAAAA, 0xCCCCCCCCCCCCCCCC, 0xF0F0F0F0F0F0F0F0,
+		0xFF00FF00FF00FF00, 0xFFFF0000FFFF0000, 0xFFFFFFFF00000000,
+	}
+	if i < 6 {
+		return patterns[i]
+	}
+	if word&(1<<(i-6)) != 0 {
+		return ^uint64(0)
+	}
+	return 0
+}
+
+// bddCounterexample builds decision diagrams of both circuits' outputs and returns
+// an assignment on which they differ. It reports false if the diagrams grew too large.
+func bddCounterexample(ctx context.Context, graphs [2]*levelGraph, inputs, outputs []string) ([]bool, bool, error) {
+	diagram := newBDD(maxBDDNodes)
+	variables := make(map[string]bddRef, len(inputs))
+	for i, title := range inputs {
+		variables[title] = diagram.variable(i)
+	}
+
+	differ, err := differingCombinations[bddRef](ctx, diagram, graphs, variables, outputs)
+	if err != nil || diagram.full {
+		return nil, false, err
+	}
+	if differ == bddFalse {
+		return nil, true, nil
+	}
+	return diagram.satisfy(differ, len(inputs)), true, nil
+}
+
+// satCounterexample encodes a miter of both circuits, which is true exactly when an
+// output differs, and solves it for an assignment on which they differ.
+func satCounterexample(ctx context.Context, graphs [2]*levelGraph, inputs, outputs []string) ([]bool, error) {
+	algebra := newCNFAlgebra()
+	variables := make(map[string]sat.Lit, len(inputs))
+	for _, title := range inputs {
+		variables[title] = algebra.cnf.NewVar()
+	}
+
+	differ, err := differingCombinations[sat.Lit](ctx, algebra, graphs, variables, outputs)
+	if err != nil {
+		return nil, err
+	}
+	algebra.cnf.Add(differ)
+	model, err := sat.Solve(ctx, algebra.cnf)
+	if errors.Is(err, sat.ErrUndecided) {
+		return nil, fmt.Errorf("could not decide whether the circuits are equivalent: %w", err)
+	}
+	if err != nil || model == nil {
+		return nil, err
+	}
+
+	assignment := make([]bool, len(inputs))
+	for i, title := range inputs {
+		assignment[i] = model[variables[title]]
+	}
+	return assignment, nil
+}
+
+// differingCombinations evaluates both circuits symbolically and returns the set of
+// input combinations for which any output differs.
+func differingCombinations[T comparable](ctx context.Context, algebra boolAlgebra[T], graphs [2]*levelGraph, variables map[string]T, outputs []string) (T, error) {
+	var values [2]map[string]logicPlanes[T]
+	for i, lg := range graphs {
+		nodeValues, err := evaluatePlanes(ctx, algebra, lg, variables)
+		if err != nil {
+			return algebra.constant(false), err
+		}
+		values[i] = outputPlanes(lg, nodeValues)
+	}
+
+	differ := algebra.constant(false)
+	for _, title := range outputs {
+		a, b := values[0][title], values[1][title]
+		for _, planes := range [][2]T{{a.t, b.t}, {a.f, b.f}, {a.x, b.x}, {a.z, b.z}} {
+			differ = algebra.or(differ, xor(algebra, planes[0], planes[1]))
+		}
+	}
+	return differ, nil
+}
+
+// boolAlgebra is a Boolean algebra over sets of input combinations: a machine word
+// holds 64 combinations, a decision diagram all of them.
+type boolAlgebra[T comparable] interface {
+	constant(value bool) T
+	and(a, b T) T
+	or(a, b T) T
+	not(a T) T
+}
+
+func xor[T comparable](algebra boolAlgebra[T], a, b T) T {
+	return algebra.or(algebra.and(a, algebra.not(b)), algebra.and(algebra.not(a), b))
+}
+
+type wordAlgebra struct{}
+
+func (wordAlgebra) constant(value bool) uint64 {
+	if value {
+		return ^uint64(0)
+	}
+	return 0
+}
+func (wordAlgebra) and(a, b uint64) uint64 { return a & b }
+func (wordAlgebra) or(a, b uint64) uint64  { return a | b }
+func (wordAlgebra) not(a uint64) uint64    { return ^a }
+
+// logicPlanes holds the value of a node for a set of input combinations as the
+// combinations for which it is true, false, unknown and high impedance. Every
+// combination is in exactly one of them.
+type logicPlanes[T comparable] struct {
+	t, f, x, z T
+}
+
+// evaluatePlanes evaluates a combinational circuit for every combination at once,
+// following evaluateNode, and returns the value of every node in the order of lg.
+func evaluatePlanes[T comparable](ctx context.Context, algebra boolAlgebra[T], lg *levelGraph, variables map[string]T) ([]logicPlanes[T], error) {
+	none, all := algebra.constant(false), algebra.constant(true)
+	unknown := logicPlanes[T]{t: none, f: none, x: all, z: none}
+
+	gateInput := func(v logicPlanes[T]) logicPlanes[T] {
+		return logicPlanes[T]{t: v.t, f: v.f, x: algebra.or(v.x, v.z), z: none}
+	}
+	resolveBus := func(drivers []logicPlanes[T]) logicPlanes[T] {
+		result := logicPlanes[T]{t: none, f: none, x: none, z: all}
+		for _, d := range drivers {
+			conflict := algebra.or(algebra.and(result.t, d.f), algebra.and(result.f, d.t))
+			// Distributed so that constant planes fold away before any new term is built.
+			result = logicPlanes[T]{
+				t: algebra.or(algebra.or(algebra.and(result.t, d.t), algebra.and(result.t, d.z)), algebra.and(result.z, d.t)),
+				f: algebra.or(algebra.or(algebra.and(result.f, d.f), algebra.and(result.f, d.z)), algebra.and(result.z, d.f)),
+				x: algebra.or(algebra.or(result.x, d.x), conflict),
+				z: algebra.and(result.z, d.z),
+			}
+		}
+		return result
+	}
+	// decided completes a gate's value: combinations that are neither true nor false are unknown.
+	decided := func(t, f T) logicPlanes[T] {
+		return logicPlanes[T]{t: t, f: f, x: algebra.not(algebra.or(t, f)), z: none}
+	}
+
+	values := make([]logicPlanes[T], len(lg.nodes))
+	for i, node := range lg.nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sources := make([]logicPlanes[T], len(lg.sources[i]))
+		for j, source := range lg.sources[i] {
+			sources[j] = values[source]
+		}
+
+		switch n := node.(type) {
+		case *InputNode:
+			values[i] = unknown
+			if variable, ok := variables[n.Title]; ok && n.Title != "" {
+				values[i] = decided(variable, algebra.not(variable))
+			}
+		case *AndNode:
+			if len(sources) == 0 {
+				values[i] = decided(none, all)
+				break
+			}
+			t, f := all, none
+			for _, source := range sources {
+				source = gateInput(source)
+				t, f = algebra.and(t, source.t), algebra.or(f, source.f)
+			}
+			values[i] = decided(t, f)
+		case *OrNode:
+			if len(sources) == 0 {
+				values[i] = decided(none, all)
+				break
+			}
+			t, f := none, all
+			for _, source := range sources {
+				source = gateInput(source)
+				t, f = algebra.or(t, source.t), algebra.and(f, source.f)
+			}
+			values[i] = decided(t, f)
+		case *NotNode:
+			input := gateInput(resolveBus(sources))
+			values[i] = decided(input.f, input.t)
+		case *TriStateNode:
+			var data, enable []logicPlanes[T]
+			for j, source := range sources {
+				if lg.ports[i][j] == PortEnable {
+					enable = append(enable, source)
+				} else {
+					data = append(data, source)
+				}
+			}
+			e, d := gateInput(resolveBus(enable)), gateInput(resolveBus(data))
+			t, f := algebra.and(e.t, d.t), algebra.and(e.t, d.f)
+			values[i] = logicPlanes[T]{t: t, f: f, x: algebra.or(algebra.and(e.t, d.x), e.x), z: e.f}
+		case *OutputNode, *wireNode:
+			values[i] = resolveBus(sources)
+		default:
+			return nil, fmt.Errorf("cannot evaluate node %s of type %T", node.GetID(), node)
+		}
+	}
+	return values, nil
+}
+
+// outputPlanes picks the values of the titled outputs from the result of evaluatePlanes.
+func outputPlanes[T comparable](lg *levelGraph, values []logicPlanes[T]) map[string]logicPlanes[T] {
+	outputs := make(map[string]logicPlanes[T])
+	for i, node := range lg.nodes {
+		if output, ok := node.(*OutputNode); ok && output.Title != "" {
+			outputs[output.Title] = values[i]
+		}
+	}
+	return outputs
+}
+
+// ioTitles returns the sorted titles of a circuit's titled inputs or outputs.
+func ioTitles(c *Circuit, inputs bool) []string {
+	titles := []string{}
+	for _, node := range c.Nodes {
+		switch n := node.(type) {
+		case *InputNode:
+			if inputs && n.Title != "" {
+				titles = append(titles, n.Title)
+			}
+		case *OutputNode:
+			if !inputs && n.Title != "" {
+				titles = append(titles, n.Title)
+			}
+		}
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+// mergeTitles returns the sorted union of two sorted title lists.
+func mergeTitles(a, b []string) []string {
+	merged := append([]string{}, a...)
+	merged = append(merged, missingTitles(b, a)...)
+	sort.Strings(merged)
+	return merged
+}
+
+// missingTitles returns the titles of a that are not in b.
+func missingTitles(a, b []string) []string {
+	var missing []string
+	for _, title := range a {
+		if !containsString(b, title) {
+			missing = append(missing, title)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,257 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildCircuit creates a circuit from its nodes and edges written "source>target",
+// or "source>target.port" for an edge into a port.
+func buildCircuit(id string, nodes []Node, edges ...string) *Circuit {
+	c := &Circuit{ID: id, Nodes: nodes}
+	for i, edge := range edges {
+		source, target, _ := strings.Cut(edge, ">")
+		target, port, _ := strings.Cut(target, ".")
+		c.Edges = append(c.Edges, &Edge{ID: fmt.Sprintf("%s-%d", id, i), SourceNodeID: source, TargetNodeID: target, TargetPort: port})
+	}
+	return c
+}
+
+func input(id, title string) Node  { return &InputNode{ID: id, Title: title} }
+func output(id, title string) Node { return &OutputNode{ID: id, Title: title} }
+
+// equivalencePairs are small circuit pairs, all with titled output Y, covering gates,
+// tri-state buffers and buses whose output is UNKNOWN or HIGH_Z.
+var equivalencePairs = []struct {
+	name       string
+	a, b       *Circuit
+	equivalent bool
+}{
+	{
+		name: "De Morgan",
+		a: buildCircuit("and", []Node{input("a", "A"), input("b", "B"), &AndNode{ID: "g"}, output("y", "Y")},
+			"a>g", "b>g", "g>y"),
+		b: buildCircuit("demorgan", []Node{input("a", "A"), input("b", "B"), &NotNode{ID: "na"}, &NotNode{ID: "nb"}, &OrNode{ID: "o"}, &NotNode{ID: "n"}, output("y", "Y")},
+			"a>na", "b>nb", "na>o", "nb>o", "o>n", "n>y"),
+		equivalent: true,
+	},
+	{
+		name: "AND and OR",
+		a: buildCircuit("and", []Node{input("a", "A"), input("b", "B"), &AndNode{ID: "g"}, output("y", "Y")},
+			"a>g", "b>g", "g>y"),
+		b: buildCircuit("or", []Node{input("a", "A"), input("b", "B"), &OrNode{ID: "g"}, output("y", "Y")},
+			"a>g", "b>g", "g>y"),
+	},
+	{
+		name: "multiplexer from tri-state buffers and from gates",
+		a: buildCircuit("bus", []Node{input("a", "A"), input("b", "B"), input("s", "S"), &NotNode{ID: "ns"}, &TriStateNode{ID: "ta"}, &TriStateNode{ID: "tb"}, output("y", "Y")},
+			"s>ns", "a>ta.data", "s>ta.enable", "b>tb.data", "ns>tb.enable", "ta>y", "tb>y"),
+		b: buildCircuit("gates", []Node{input("a", "A"), input("b", "B"), input("s", "S"), &NotNode{ID: "ns"}, &AndNode{ID: "ga"}, &AndNode{ID: "gb"}, &OrNode{ID: "o"}, output("y", "Y")},
+			"s>ns", "a>ga", "s>ga", "b>gb", "ns>gb", "ga>o", "gb>o", "o>y"),
+		equivalent: true,
+	},
+	{
+		name: "tri-state buffer and wire",
+		a: buildCircuit("buffer", []Node{input("a", "A"), input("e", "E"), &TriStateNode{ID: "t"}, output("y", "Y")},
+			"a>t.data", "e>t.enable", "t>y"),
+		b: buildCircuit("wire", []Node{input("a", "A"), output("y", "Y")},
+			"a>y"),
+	},
+	{
+		name: "conflicting drivers",
+		// Both buses are UNKNOWN when E is true and HIGH_Z otherwise, whatever A and B are.
+		a: buildCircuit("conflictA", []Node{input("a", "A"), input("e", "E"), &NotNode{ID: "na"}, &TriStateNode{ID: "t1"}, &TriStateNode{ID: "t2"}, output("y", "Y")},
+			"a>na", "a>t1.data", "e>t1.enable", "na>t2.data", "e>t2.enable", "t1>y", "t2>y"),
+		b: buildCircuit("conflictB", []Node{input("b", "B"), input("e", "E"), &NotNode{ID: "nb"}, &TriStateNode{ID: "t1"}, &TriStateNode{ID: "t2"}, output("y", "Y")},
+			"b>nb", "b>t1.data", "e>t1.enable", "nb>t2.data", "e>t2.enable", "t1>y", "t2>y"),
+		equivalent: true,
+	},
+	{
+		name: "conflicting drivers and a single driver",
+		a: buildCircuit("conflict", []Node{input("a", "A"), input("e", "E"), &NotNode{ID: "na"}, &TriStateNode{ID: "t1"}, &TriStateNode{ID: "t2"}, output("y", "Y")},
+			"a>na", "a>t1.data", "e>t1.enable", "na>t2.data", "e>t2.enable", "t1>y", "t2>y"),
+		b: buildCircuit("single", []Node{input("a", "A"), input("e", "E"), &TriStateNode{ID: "t1"}, output("y", "Y")},
+			"a>t1.data", "e>t1.enable", "t1>y"),
+	},
+	{
+		name: "HIGH_Z through a gate",
+		// A gate reads a floating input as UNKNOWN, unlike a wire.
+		a: buildCircuit("gate", []Node{input("a", "A"), input("e", "E"), &TriStateNode{ID: "t"}, &OrNode{ID: "o"}, output("y", "Y")},
+			"a>t.data", "e>t.enable", "t>o", "o>y"),
+		b: buildCircuit("wire", []Node{input("a", "A"), input("e", "E"), &TriStateNode{ID: "t"}, output("y", "Y")},
+			"a>t.data", "e>t.enable", "t>y"),
+	},
+	{
+		name: "unknown enable",
+		// A tri-state buffer with an unknown enable may or may not drive its output.
+		a: buildCircuit("buffer", []Node{input("a", "A"), &InputNode{ID: "u"}, &TriStateNode{ID: "t"}, output("y", "Y")},
+			"a>t.data", "u>t.enable", "t>y"),
+		b: buildCircuit("unknown", []Node{input("a", "A"), &InputNode{ID: "u"}, &NotNode{ID: "n"}, output("y", "Y")},
+			"u>n", "n>y"),
+		equivalent: true,
+	},
+	{
+		name: "unknown input masked by a controlling value",
+		// The untitled input is unknown, which only matters when A is true.
+		a: buildCircuit("masked", []Node{input("a", "A"), &InputNode{ID: "u"}, &NotNode{ID: "na"}, &AndNode{ID: "g"}, &OrNode{ID: "o"}, output("y", "Y")},
+			"a>na", "a>g", "u>g", "g>o", "na>o", "o>y"),
+		b: buildCircuit("not", []Node{input("a", "A"), &NotNode{ID: "na"}, output("y", "Y")},
+			"a>na", "na>y"),
+	},
+}
+
+// allInputs returns every assignment of the titled inputs, in the given order.
+func allInputs(titles []string) [][]*InputNodeValue {
+	var assignments [][]*InputNodeValue
+	for m := 0; m < 1<<len(titles); m++ {
+		inputs := make([]*InputNodeValue, len(titles))
+		for i, title := range titles {
+			value := m&(1<<i) != 0
+			inputs[i] = &InputNodeValue{Title: title, Value: &value}
+		}
+		assignments = append(assignments, inputs)
+	}
+	return assignments
+}
+
+// bruteForceDifferences evaluates both circuits on every assignment of the inputs
+// and returns the assignments on which they differ, keyed by their bits.
+func bruteForceDifferences(t *testing.T, a, b *Circuit, inputs []string) map[string]bool {
+	t.Helper()
+	differing := make(map[string]bool)
+	for _, assignment := range allInputs(inputs) {
+		differences, err := diffCircuits(context.Background(), a, b, assignment)
+		if err != nil {
+			t.Fatalf("diffCircuits() error = %v", err)
+		}
+		if len(differences) > 0 {
+			differing[assignmentKey(assignment)] = true
+		}
+	}
+	return differing
+}
+
+func assignmentKey(assignment []*InputNodeValue) string {
+	key := ""
+	for _, input := range assignment {
+		key += fmt.Sprintf("%s=%v ", input.Title, *input.Value)
+	}
+	return key
+}
+
+func TestEquivalenceMethodsAgree(t *testing.T) {
+	ctx := context.Background()
+	methods := []struct {
+		name           string
+		counterexample func(graphs [2]*levelGraph, inputs, outputs []string) ([]bool, error)
+	}{
+		{"exhaustive", func(graphs [2]*levelGraph, inputs, outputs []string) ([]bool, error) {
+			return exhaustiveCounterexample(ctx, graphs, inputs, outputs)
+		}},
+		{"BDD", func(graphs [2]*levelGraph, inputs, outputs []string) ([]bool, error) {
+			assignment, complete, err := bddCounterexample(ctx, graphs, inputs, outputs)
+			if err == nil && !complete {
+				err = fmt.Errorf("decision diagram is incomplete")
+			}
+			return assignment, err
+		}},
+		{"SAT", func(graphs [2]*levelGraph, inputs, outputs []string) ([]bool, error) {
+			return satCounterexample(ctx, graphs, inputs, outputs)
+		}},
+	}
+
+	for _, pair := range equivalencePairs {
+		t.Run(pair.name, func(t *testing.T) {
+			var graphs [2]*levelGraph
+			for i, c := range []*Circuit{pair.a, pair.b} {
+				validated, err := c.Validate()
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				graphs[i] = newLevelGraph(validated.graph)
+			}
+			inputs := mergeTitles(ioTitles(pair.a, true), ioTitles(pair.b, true))
+			outputs := ioTitles(pair.a, false)
+
+			differing := bruteForceDifferences(t, pair.a, pair.b, inputs)
+			if (len(differing) == 0) != pair.equivalent {
+				t.Fatalf("evaluation finds %d differing assignments, want equivalent = %v", len(differing), pair.equivalent)
+			}
+
+			for _, method := range methods {
+				assignment, err := method.counterexample(graphs, inputs, outputs)
+				if err != nil {
+					t.Fatalf("%s: error = %v", method.name, err)
+				}
+				if (assignment == nil) != pair.equivalent {
+					t.Errorf("%s: counterexample = %v, want equivalent = %v", method.name, assignment, pair.equivalent)
+					continue
+				}
+				if assignment == nil {
+					continue
+				}
+				counterexample := make([]*InputNodeValue, len(inputs))
+				for i, title := range inputs {
+					value := assignment[i]
+					counterexample[i] = &InputNodeValue{Title: title, Value: &value}
+				}
+				if key := assignmentKey(counterexample); !differing[key] {
+					t.Errorf("%s: circuits agree on counterexample %s", method.name, key)
+				}
+			}
+
+			result, err := CheckEquivalence(ctx, pair.a, pair.b)
+			if err != nil {
+				t.Fatalf("CheckEquivalence() error = %v", err)
+			}
+			if result.Equivalent != pair.equivalent {
+				t.Errorf("CheckEquivalence() equivalent = %v, want %v", result.Equivalent, pair.equivalent)
+			}
+			if !result.Equivalent && len(result.Differences) == 0 {
+				t.Errorf("CheckEquivalence() reports no differences for counterexample %s", assignmentKey(result.Counterexample))
+			}
+		})
+	}
+}
+
+func TestCheckEquivalenceRejectsDifferentOutputs(t *testing.T) {
+	a := buildCircuit("a", []Node{input("a", "A"), output("y", "Y")}, "a>y")
+	b := buildCircuit("b", []Node{input("a", "A"), output("z", "Z")}, "a>z")
+	if _, err := CheckEquivalence(context.Background(), a, b); err == nil {
+		t.Fatal("expected an error for circuits with different outputs")
+	}
+}
+
+func TestBDDReportsFull(t *testing.T) {
+	// The parity of n variables needs a node per variable and parity, so a small
+	// limit is exceeded.
+	diagram := newBDD(16)
+	parity := diagram.constant(false)
+	for level := 0; level < 12; level++ {
+		parity = xor[bddRef](diagram, parity, diagram.variable(level))
+	}
+	if !diagram.full {
+		t.Fatal("expected the diagram to be full")
+	}
+
+	diagram = newBDD(maxBDDNodes)
+	parity = diagram.constant(false)
+	for level := 0; level < 12; level++ {
+		parity = xor[bddRef](diagram, parity, diagram.variable(level))
+	}
+	if diagram.full {
+		t.Fatal("diagram is full")
+	}
+	assignment := diagram.satisfy(parity, 12)
+	ones := 0
+	for _, value := range assignment {
+		if value {
+			ones++
+		}
+	}
+	if ones%2 != 1 {
+		t.Fatalf("satisfy() = %v, which has even parity", assignment)
+	}
+}
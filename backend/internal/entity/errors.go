@@ -0,0 +1,15 @@
+package entity
+
+import "fmt"
+
+// ConflictError is returned when a write was based on a stale version of a circuit,
+// i.e. someone else changed the circuit since the caller last read it.
+type ConflictError struct {
+	CircuitID       string
+	ExpectedVersion int
+	CurrentVersion  int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict: circuit %s is at version %d, expected version %d", e.CircuitID, e.CurrentVersion, e.ExpectedVersion)
+}
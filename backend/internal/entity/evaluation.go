@@ -1,130 +1,305 @@
 package entity
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
 )
 
 type EvaluationResult struct {
-	Success bool          `json:"success"`
-	Outputs []*NodeOutput `json:"outputs"`
-	Error   string        `json:"error"`
+	Success      bool          `json:"success"`
+	Outputs      []*NodeOutput `json:"outputs"`
+	NamedOutputs NamedOutputs  `json:"namedOutputs"`
+	Error        string        `json:"error"`
+	// SessionID identifies the live evaluation that produced the result, if any.
+	SessionID string `json:"sessionID,omitempty"`
 }
 
+// NodeOutput is the value of an OutputNode. Value is true only when State is TRUE,
+// so clients that predate State read UNKNOWN and HIGH_Z as false.
 type NodeOutput struct {
-	NodeID string `json:"nodeID"`
-	Value  bool   `json:"value"`
+	NodeID string     `json:"nodeID"`
+	Title  string     `json:"title"`
+	Value  bool       `json:"value"`
+	State  LogicValue `json:"state"`
 }
 
+// InputNodeValue sets the value of an InputNode, identified either by its ID or,
+// when NodeID is empty, by its title. A nil Value leaves the input unknown.
 type InputNodeValue struct {
 	NodeID string `json:"nodeID"`
-	Value  bool   `json:"value"`
+	Title  string `json:"title"`
+	Value  *bool  `json:"value"`
+}
+
+// state returns the input's value as a LogicValue.
+func (v *InputNodeValue) state() LogicValue {
+	if v.Value == nil {
+		return LogicUnknown
+	}
+	return LogicFromBool(*v.Value)
 }
 
-// EvaluateCircuit evaluates a boolean circuit with given input values
-func (c *Circuit) EvaluateCircuit(inputs []*InputNodeValue) (*EvaluationResult, error) {
+// EvaluateCircuit evaluates a boolean circuit with given input values.
+// Inputs that are not given are unknown, and outputs that depend on them are
+// reported as unknown unless the known inputs already decide them.
+// Flip-flops and latches are treated as holding an unknown value; use a Simulation
+// to step sequential circuits.
+// Evaluation stops early with ctx.Err() if ctx is cancelled.
+func (c *Circuit) EvaluateCircuit(ctx context.Context, inputs []*InputNodeValue) (*EvaluationResult, error) {
 	// It's good practice to validate the circuit structure before evaluation.
-	if err := c.ValidateCircuit(); err != nil {
-		return &EvaluationResult{Success: false, Error: err.Error()}, err
+	validated, err := c.Validate()
+	if err != nil {
+		return failedResult(err)
 	}
 
-	// --- 1. Setup ---
-	nodeMap := make(map[string]Node)
+	return validated.Evaluate(ctx, inputs)
+}
+
+// Evaluate evaluates the validated circuit with given input values. Large circuits
+// are evaluated level by level across a bounded pool of goroutines; small ones
+// sequentially, where the coordination overhead would outweigh the gain.
+func (v *ValidatedCircuit) Evaluate(ctx context.Context, inputs []*InputNodeValue) (*EvaluationResult, error) {
+	c := v.circuit
+
+	// --- 1. Evaluation ---
+	var computedValues map[string]LogicValue
+	var err error
+	if workers := parallelWorkers(len(v.graph.order)); workers > 1 {
+		computedValues, err = v.computeValuesParallel(ctx, inputs, workers)
+	} else {
+		computedValues, err = c.computeValues(ctx, v.graph, inputs, nil)
+	}
+	if err != nil {
+		return failedResult(err)
+	}
+
+	// --- 2. Collect Results ---
+	outputs, err := c.collectOutputs(computedValues)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	return successfulResult(outputs), nil
+}
+
+// successfulResult wraps outputs in an EvaluationResult, keying titled outputs by title.
+func successfulResult(outputs []*NodeOutput) *EvaluationResult {
+	named := make(NamedOutputs)
+	shared := make(map[string]bool)
+	for _, output := range outputs {
+		if output.Title == "" {
+			continue
+		}
+		if _, exists := named[output.Title]; exists {
+			shared[output.Title] = true
+		}
+		named[output.Title] = output.State
+	}
+	for title := range shared {
+		delete(named, title)
+	}
+	return &EvaluationResult{Success: true, Outputs: outputs, NamedOutputs: named}
+}
+
+// failedResult reports err both as an unsuccessful EvaluationResult and as an error.
+func failedResult(err error) (*EvaluationResult, error) {
+	return &EvaluationResult{Success: false, Error: err.Error()}, err
+}
+
+// evaluationGraph is the wiring of a circuit prepared for evaluation.
+type evaluationGraph struct {
+	nodeMap map[string]Node
 	// This map stores incoming connections for each node.
 	// Key: targetNodeID, Value: list of sourceNodeIDs that feed into it.
-	incomingEdges := make(map[string][]string)
-	for _, node := range c.Nodes {
-		nodeMap[node.GetID()] = node
-		incomingEdges[node.GetID()] = []string{} // Initialize with empty slice
+	incomingEdges map[string][]string
+	// incomingPorts holds the target port of each entry of incomingEdges.
+	incomingPorts map[string][]string
+	// stateEdges and statePorts hold the edges into state nodes (see isStateNode)
+	// in the same form. They are kept apart so feedback through state nodes is not a cycle.
+	stateEdges map[string][]string
+	statePorts map[string][]string
+	// order lists node IDs so that every node comes after all of its sources.
+	order []string
+	// inputsByTitle maps input titles to node IDs, see Circuit.InputsByTitle.
+	inputsByTitle map[string]string
+}
+
+// inputNodeID resolves the InputNode an input value refers to.
+func (g *evaluationGraph) inputNodeID(input *InputNodeValue) (string, error) {
+	if input.NodeID == "" {
+		if input.Title == "" {
+			return "", errors.New("input must have a nodeID or a title")
+		}
+		nodeID, exists := g.inputsByTitle[input.Title]
+		if !exists {
+			return "", fmt.Errorf("no InputNode titled '%s'", input.Title)
+		}
+		if nodeID == "" {
+			return "", fmt.Errorf("several InputNodes are titled '%s'; address the input by nodeID", input.Title)
+		}
+		return nodeID, nil
 	}
-	for _, edge := range c.Edges {
-		incomingEdges[edge.TargetNodeID] = append(incomingEdges[edge.TargetNodeID], edge.SourceNodeID)
+
+	if _, ok := g.nodeMap[input.NodeID].(*InputNode); !ok {
+		return "", fmt.Errorf("provided input '%s' is not an InputNode", input.NodeID)
 	}
+	return input.NodeID, nil
+}
+
+func (c *Circuit) buildEvaluationGraph() (*evaluationGraph, error) {
+	graph := c.newEvaluationGraph()
 
-	// --- 2. Topological Sort ---
 	// Get the correct order to ensure nodes are evaluated only after their inputs are.
-	evaluationOrder, err := topologicalSort(incomingEdges)
+	order, err := topologicalSort(graph.incomingEdges)
 	if err != nil {
-		return &EvaluationResult{Success: false, Error: err.Error()}, err
+		return nil, err
 	}
+	graph.order = order
+	return graph, nil
+}
 
-	// --- 3. Evaluation ---
-	computedValues := make(map[string]bool)
+// newEvaluationGraph collects the wiring of the circuit without ordering it.
+func (c *Circuit) newEvaluationGraph() *evaluationGraph {
+	graph := &evaluationGraph{
+		nodeMap:       make(map[string]Node),
+		incomingEdges: make(map[string][]string),
+		incomingPorts: make(map[string][]string),
+		stateEdges:    make(map[string][]string),
+		statePorts:    make(map[string][]string),
+		inputsByTitle: c.InputsByTitle(),
+	}
+	for _, node := range c.Nodes {
+		graph.nodeMap[node.GetID()] = node
+		graph.incomingEdges[node.GetID()] = []string{} // Initialize with empty slice
+	}
+	for _, edge := range c.Edges {
+		if isStateNode(graph.nodeMap[edge.TargetNodeID]) {
+			graph.stateEdges[edge.TargetNodeID] = append(graph.stateEdges[edge.TargetNodeID], edge.SourceNodeID)
+			graph.statePorts[edge.TargetNodeID] = append(graph.statePorts[edge.TargetNodeID], edge.TargetPort)
+			continue
+		}
+		graph.incomingEdges[edge.TargetNodeID] = append(graph.incomingEdges[edge.TargetNodeID], edge.SourceNodeID)
+		graph.incomingPorts[edge.TargetNodeID] = append(graph.incomingPorts[edge.TargetNodeID], edge.TargetPort)
+	}
+	return graph
+}
+
+// computeValues evaluates every node of graph in order and returns each node's value.
+// State nodes output their value in state, or unknown if state does not hold one.
+func (c *Circuit) computeValues(ctx context.Context, graph *evaluationGraph, inputs []*InputNodeValue, state map[string]LogicValue) (map[string]LogicValue, error) {
+	computedValues := make(map[string]LogicValue)
 	// Pre-populate computedValues with the provided external inputs.
 	for _, input := range inputs {
-		if _, ok := nodeMap[input.NodeID].(*InputNode); !ok {
-			err := fmt.Errorf("provided input '%s' is not an InputNode", input.NodeID)
-			return &EvaluationResult{Success: false, Error: err.Error()}, err
+		nodeID, err := graph.inputNodeID(input)
+		if err != nil {
+			return nil, err
 		}
-		computedValues[input.NodeID] = input.Value
+		computedValues[nodeID] = input.state()
 	}
 
 	// Evaluate nodes in their topologically sorted order.
-	for _, nodeID := range evaluationOrder {
-		node := nodeMap[nodeID]
+	for _, nodeID := range graph.order {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		node := graph.nodeMap[nodeID]
 
-		// Input nodes are our starting point; their values are already in computedValues.
+		// Input nodes are our starting point; those without a provided value are unknown.
 		if _, ok := node.(*InputNode); ok {
 			if _, exists := computedValues[nodeID]; !exists {
-				err := fmt.Errorf("missing value for InputNode: %s", nodeID)
-				return &EvaluationResult{Success: false, Error: err.Error()}, err
+				computedValues[nodeID] = LogicUnknown
 			}
 			continue
 		}
-
-		// Gather the computed values from all incoming connections.
-		sourceNodeIDs := incomingEdges[nodeID]
-		inputValues := make([]bool, 0, len(sourceNodeIDs))
-		for _, sourceNodeID := range sourceNodeIDs {
-			value, exists := computedValues[sourceNodeID]
+		if isStateNode(node) {
+			value, exists := state[nodeID]
 			if !exists {
-				// This should not happen if the topological sort is correct and all inputs are provided.
-				err := fmt.Errorf("internal evaluation error: input value for node %s from source %s not computed", nodeID, sourceNodeID)
-				return &EvaluationResult{Success: false, Error: err.Error()}, err
+				value = LogicUnknown
 			}
-			inputValues = append(inputValues, value)
+			computedValues[nodeID] = value
+			continue
 		}
 
-		// Evaluate the current node and store its result.
-		result, err := c.evaluateNode(node, inputValues)
+		result, err := c.recomputeNode(graph, nodeID, computedValues)
 		if err != nil {
-			err = fmt.Errorf("failed to evaluate node %s: %w", nodeID, err)
-			return &EvaluationResult{Success: false, Error: err.Error()}, err
+			return nil, err
 		}
 		computedValues[nodeID] = result
 	}
 
-	// --- 4. Collect Results ---
+	return computedValues, nil
+}
+
+// recomputeNode evaluates a single non-input node from the current values of its sources.
+func (c *Circuit) recomputeNode(graph *evaluationGraph, nodeID string, computedValues map[string]LogicValue) (LogicValue, error) {
+	// Gather the computed values from all incoming connections.
+	sourceNodeIDs := graph.incomingEdges[nodeID]
+	inputValues := make([]LogicValue, 0, len(sourceNodeIDs))
+	for _, sourceNodeID := range sourceNodeIDs {
+		value, exists := computedValues[sourceNodeID]
+		if !exists {
+			// This should not happen if the topological sort is correct.
+			return LogicUnknown, fmt.Errorf("internal evaluation error: input value for node %s from source %s not computed", nodeID, sourceNodeID)
+		}
+		inputValues = append(inputValues, value)
+	}
+
+	// Evaluate the current node.
+	result, err := c.evaluateNode(graph.nodeMap[nodeID], inputValues, graph.incomingPorts[nodeID])
+	if err != nil {
+		return LogicUnknown, fmt.Errorf("failed to evaluate node %s: %w", nodeID, err)
+	}
+	return result, nil
+}
+
+// collectOutputs reads the values of all OutputNodes, ordered by title and then ID
+// so results are stable regardless of the order nodes were loaded in.
+func (c *Circuit) collectOutputs(computedValues map[string]LogicValue) ([]*NodeOutput, error) {
 	var outputs []*NodeOutput
 	for _, node := range c.Nodes {
-		if _, ok := node.(*OutputNode); ok {
+		if outputNode, ok := node.(*OutputNode); ok {
 			if value, exists := computedValues[node.GetID()]; exists {
-				outputs = append(outputs, &NodeOutput{NodeID: node.GetID(), Value: value})
+				outputs = append(outputs, &NodeOutput{NodeID: node.GetID(), Title: outputNode.Title, Value: value == LogicTrue, State: value})
 			} else {
 				// This can happen if an output node is disconnected from all inputs.
-				err := fmt.Errorf("output node %s was not evaluated, check circuit connections", node.GetID())
-				return &EvaluationResult{Success: false, Error: err.Error()}, err
+				return nil, fmt.Errorf("output node %s was not evaluated, check circuit connections", node.GetID())
 			}
 		}
 	}
 
-	return &EvaluationResult{Success: true, Outputs: outputs}, nil
+	sortNodeOutputs(outputs)
+	return outputs, nil
+}
+
+// sortNodeOutputs orders node values by title and then ID.
+func sortNodeOutputs(outputs []*NodeOutput) {
+	sort.Slice(outputs, func(i, j int) bool {
+		if outputs[i].Title != outputs[j].Title {
+			return outputs[i].Title < outputs[j].Title
+		}
+		return outputs[i].NodeID < outputs[j].NodeID
+	})
 }
 
-// evaluateNode evaluates a single node based on its type and input values
-func (c *Circuit) evaluateNode(node Node, inputValues []bool) (bool, error) {
+// evaluateNode evaluates a single node based on its type and input values. ports
+// holds the target port of each input value.
+//
+// AND and OR gates treat every incoming edge as a separate gate input. Every other
+// node has a single input net (or, for a TriStateNode, one per port), and all
+// edges into it are drivers of that net, resolved by resolveBus.
+func (c *Circuit) evaluateNode(node Node, inputValues []LogicValue, ports []string) (LogicValue, error) {
 	switch node.(type) {
 	case *InputNode:
 		// This case should not be reached in the new evaluation flow, as input values are pre-populated.
 		// Its existence is a safeguard against logic errors.
-		return false, errors.New("internal evaluation error: evaluateNode called on InputNode")
+		return LogicUnknown, errors.New("internal evaluation error: evaluateNode called on InputNode")
 
 	case *OutputNode:
-		// Output nodes pass through their input value
-		if len(inputValues) != 1 {
-			return false, errors.New("output node must have exactly one input")
-		}
-		return inputValues[0], nil
+		// Output nodes pass through the value of their input net
+		return resolveBus(inputValues), nil
 
 	case *AndNode:
 		return evaluateAnd(inputValues), nil
@@ -133,68 +308,109 @@ func (c *Circuit) evaluateNode(node Node, inputValues []bool) (bool, error) {
 		return evaluateOr(inputValues), nil
 
 	case *NotNode:
-		return evaluateNot(inputValues), nil
+		return evaluateNot(resolveBus(inputValues)), nil
+
+	case *TriStateNode:
+		return evaluateTriState(inputValues, ports), nil
+
+	case *DFlipFlopNode, *SRLatchNode:
+		// State nodes output their held value, which is never derived from their inputs here.
+		return LogicUnknown, fmt.Errorf("internal evaluation error: evaluateNode called on state node %T", node)
+
+	case *wireNode:
+		return resolveBus(inputValues), nil
 
 	case *CircuitNode:
-		// For circuit nodes, we would need to recursively evaluate the sub-circuit
-		// This is a simplified implementation
-		if len(inputValues) != 1 {
-			return false, errors.New("circuit node must have exactly one input")
-		}
-		return inputValues[0], nil
+		// A CircuitNode has an output per port of the circuit it references, which only
+		// flattening can tell apart (see Flatten).
+		return LogicUnknown, fmt.Errorf("circuit node %s must be flattened before evaluation", node.GetID())
 
 	default:
-		return false, fmt.Errorf("unknown node type: %T", node)
+		return LogicUnknown, fmt.Errorf("unknown node type: %T", node)
 	}
 }
 
-// evaluateAnd performs AND operation on input values
-func evaluateAnd(inputs []bool) bool {
+// evaluateAnd performs AND operation on input values. A single false input decides
+// the result even when other inputs are unknown.
+func evaluateAnd(inputs []LogicValue) LogicValue {
 	if len(inputs) == 0 {
-		return false
+		return LogicFalse
 	}
 
+	result := LogicTrue
 	for _, input := range inputs {
-		if !input {
-			return false
+		switch gateInput(input) {
+		case LogicFalse:
+			return LogicFalse
+		case LogicUnknown:
+			result = LogicUnknown
 		}
 	}
-	return true
+	return result
 }
 
-// evaluateOr performs OR operation on input values
-func evaluateOr(inputs []bool) bool {
+// evaluateOr performs OR operation on input values. A single true input decides
+// the result even when other inputs are unknown.
+func evaluateOr(inputs []LogicValue) LogicValue {
 	if len(inputs) == 0 {
-		return false
+		return LogicFalse
 	}
 
+	result := LogicFalse
 	for _, input := range inputs {
-		if input {
-			return true
+		switch gateInput(input) {
+		case LogicTrue:
+			return LogicTrue
+		case LogicUnknown:
+			result = LogicUnknown
 		}
 	}
-	return false
+	return result
 }
 
-// evaluateNot performs NOT operation on input values
-func evaluateNot(inputs []bool) bool {
-	if len(inputs) != 1 {
-		return false // Default to false if not exactly one input
+// evaluateNot performs NOT operation on the value of its input net
+func evaluateNot(input LogicValue) LogicValue {
+	switch gateInput(input) {
+	case LogicFalse:
+		return LogicTrue
+	case LogicTrue:
+		return LogicFalse
 	}
-	return !inputs[0]
+	return LogicUnknown
 }
 
-// topologicalSort performs topological sorting to determine evaluation order
-func topologicalSort(dependencies map[string][]string) ([]string, error) {
-	// Calculate in-degrees
-	inDegree := make(map[string]int)
-	for node := range dependencies {
-		inDegree[node] = 0
+// evaluateTriState drives the data net onto the output while the enable net is
+// true, and releases the output (high impedance) while it is false.
+func evaluateTriState(inputs []LogicValue, ports []string) LogicValue {
+	var data, enable []LogicValue
+	for i, input := range inputs {
+		if ports[i] == PortEnable {
+			enable = append(enable, input)
+		} else {
+			data = append(data, input)
+		}
 	}
 
-	// For each node, count how many dependencies it has
+	switch gateInput(resolveBus(enable)) {
+	case LogicTrue:
+		return gateInput(resolveBus(data))
+	case LogicFalse:
+		return LogicHighZ
+	}
+	return LogicUnknown
+}
+
+// topologicalSort performs topological sorting to determine evaluation order.
+// It runs in O(V+E) so that very large circuits stay cheap to validate.
+func topologicalSort(dependencies map[string][]string) ([]string, error) {
+	// Calculate in-degrees, and for each node the nodes that depend on it
+	inDegree := make(map[string]int, len(dependencies))
+	dependents := make(map[string][]string, len(dependencies))
 	for node, deps := range dependencies {
 		inDegree[node] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], node)
+		}
 	}
 
 	// Find nodes with no dependencies (can be evaluated first)
@@ -205,7 +421,7 @@ func topologicalSort(dependencies map[string][]string) ([]string, error) {
 		}
 	}
 
-	var result []string
+	result := make([]string, 0, len(dependencies))
 
 	// Process nodes
 	for len(queue) > 0 {
@@ -214,16 +430,11 @@ func topologicalSort(dependencies map[string][]string) ([]string, error) {
 		queue = queue[1:]
 		result = append(result, current)
 
-		// Find all nodes that depend on the current node and reduce their in-degree
-		for node, deps := range dependencies {
-			for _, dep := range deps {
-				if dep == current {
-					inDegree[node]--
-					if inDegree[node] == 0 {
-						queue = append(queue, node)
-					}
-					break
-				}
+		// Reduce the in-degree of all nodes that depend on the current node
+		for _, node := range dependents[current] {
+			inDegree[node]--
+			if inDegree[node] == 0 {
+				queue = append(queue, node)
 			}
 		}
 	}
@@ -236,8 +447,41 @@ func topologicalSort(dependencies map[string][]string) ([]string, error) {
 	return result, nil
 }
 
+// ValidatedCircuit is a circuit that passed validation, together with the evaluation
+// order computed while validating it. Evaluating through a ValidatedCircuit avoids
+// repeating that work.
+type ValidatedCircuit struct {
+	circuit *Circuit
+	graph   *evaluationGraph
+
+	// levels is built on first parallel evaluation and reused afterwards.
+	levelsOnce sync.Once
+	levels     *levelGraph
+}
+
 // ValidateCircuit validates that the circuit is properly constructed
 func (c *Circuit) ValidateCircuit() error {
+	_, err := c.Validate()
+	return err
+}
+
+// Validate validates that the circuit is properly constructed and prepares it for evaluation
+func (c *Circuit) Validate() (*ValidatedCircuit, error) {
+	if err := c.checkStructure(); err != nil {
+		return nil, err
+	}
+
+	// Check for cycles while computing the evaluation order
+	graph, err := c.buildEvaluationGraph()
+	if err != nil {
+		return nil, fmt.Errorf("circuit validation failed: %w", err)
+	}
+
+	return &ValidatedCircuit{circuit: c, graph: graph}, nil
+}
+
+// checkStructure performs every validation check except the one for cycles.
+func (c *Circuit) checkStructure() error {
 	if c == nil {
 		return errors.New("circuit is nil")
 	}
@@ -247,38 +491,32 @@ func (c *Circuit) ValidateCircuit() error {
 	}
 
 	// Check for duplicate node IDs
-	nodeIDs := make(map[string]bool)
+	nodes := make(map[string]Node)
 	for _, node := range c.Nodes {
-		if nodeIDs[node.GetID()] {
+		if _, exists := nodes[node.GetID()]; exists {
 			return fmt.Errorf("duplicate node ID: %s", node.GetID())
 		}
-		nodeIDs[node.GetID()] = true
+		nodes[node.GetID()] = node
 	}
 
-	// Check that all edge references are valid
+	// Check that all edge references are valid, and that every edge feeds an input
+	// port its target has. A node's input may have several drivers; they are
+	// resolved during evaluation.
 	for _, edge := range c.Edges {
-		if !nodeIDs[edge.SourceNodeID] {
+		source, exists := nodes[edge.SourceNodeID]
+		if !exists {
 			return fmt.Errorf("edge references non-existent source node: %s", edge.SourceNodeID)
 		}
-		if !nodeIDs[edge.TargetNodeID] {
+		target, exists := nodes[edge.TargetNodeID]
+		if !exists {
 			return fmt.Errorf("edge references non-existent target node: %s", edge.TargetNodeID)
 		}
+		if err := ValidateSourcePort(source, edge.SourcePort); err != nil {
+			return fmt.Errorf("edge %s: %w", edge.ID, err)
+		}
+		if err := ValidateTargetPort(target, edge.TargetPort); err != nil {
+			return fmt.Errorf("edge %s: %w", edge.ID, err)
+		}
 	}
-
-	// Check for cycles
-	dependencies := make(map[string][]string)
-	for _, node := range c.Nodes {
-		dependencies[node.GetID()] = []string{}
-	}
-
-	for _, edge := range c.Edges {
-		dependencies[edge.TargetNodeID] = append(dependencies[edge.TargetNodeID], edge.SourceNodeID)
-	}
-
-	_, err := topologicalSort(dependencies)
-	if err != nil {
-		return fmt.Errorf("circuit validation failed: %w", err)
-	}
-
 	return nil
 }
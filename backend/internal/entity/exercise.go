@@ -0,0 +1,485 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxExerciseInputs bounds the inputs of an exercise, as grading checks every row
+	// of the truth table.
+	maxExerciseInputs = 16
+	// maxFailingRows caps the failing rows reported for a submission.
+	maxFailingRows = 100
+)
+
+// TruthTableRow specifies the outputs of an exercise for one combination of inputs,
+// in the order of the exercise's inputs and outputs. A nil output is a don't-care.
+type TruthTableRow struct {
+	Inputs  []bool  `json:"inputs"`
+	Outputs []*bool `json:"outputs"`
+}
+
+// Exercise asks for a circuit with the given inputs and outputs, addressed by title,
+// that computes a truth table. Rows missing from the truth table are don't-cares.
+// An exercise created from a reference circuit stores the reference's truth table,
+// so later changes to the reference do not change the exercise.
+type Exercise struct {
+	ID                 string           `json:"id"`
+	Title              string           `json:"title"`
+	Description        string           `json:"description"`
+	Inputs             []string         `json:"inputs"`
+	Outputs            []string         `json:"outputs"`
+	TruthTable         []*TruthTableRow `json:"truthTable"`
+	ReferenceCircuitID string           `json:"referenceCircuitID"`
+	// AllowedGateTypes restricts the gates a solution may use; empty allows all.
+	AllowedGateTypes []NodeType `json:"allowedGateTypes"`
+	MaxGates         *int32     `json:"maxGates"`
+	MaxDepth         *int32     `json:"maxDepth"`
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+// ViolationKind classifies a ConstraintViolation.
+type ViolationKind string
+
+const (
+	// ViolationInterface means an input or output is missing or not asked for.
+	ViolationInterface ViolationKind = "INTERFACE"
+	// ViolationStructure means the circuit is not a valid combinational circuit.
+	ViolationStructure ViolationKind = "STRUCTURE"
+	// ViolationGateType means the circuit uses a gate type that is not allowed.
+	ViolationGateType ViolationKind = "GATE_TYPE"
+	// ViolationGateCount means the circuit uses more gates than allowed.
+	ViolationGateCount ViolationKind = "GATE_COUNT"
+	// ViolationDepth means a path through the circuit passes more gates than allowed.
+	ViolationDepth ViolationKind = "DEPTH"
+)
+
+func (k ViolationKind) IsValid() bool {
+	switch k {
+	case ViolationInterface, ViolationStructure, ViolationGateType, ViolationGateCount, ViolationDepth:
+		return true
+	}
+	return false
+}
+
+func (k ViolationKind) String() string {
+	return string(k)
+}
+
+func (k *ViolationKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*k = ViolationKind(str)
+	if !k.IsValid() {
+		return fmt.Errorf("%s is not a valid ViolationKind", str)
+	}
+	return nil
+}
+
+func (k ViolationKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(k.String()))
+}
+
+// ConstraintViolation is a requirement of an exercise a submission does not meet.
+type ConstraintViolation struct {
+	Kind    ViolationKind `json:"kind"`
+	Message string        `json:"message"`
+}
+
+// FailingRow is a row of the truth table a submission gets wrong.
+type FailingRow struct {
+	// Inputs are the values of the exercise's inputs, in the exercise's order.
+	Inputs     []*NodeOutput     `json:"inputs"`
+	Mismatches []*OutputMismatch `json:"mismatches"`
+}
+
+// ExerciseSubmission is the graded outcome of submitting a circuit for an exercise.
+type ExerciseSubmission struct {
+	ID             string `json:"id"`
+	ExerciseID     string `json:"exerciseID"`
+	CircuitID      string `json:"circuitID"`
+	CircuitVersion int32  `json:"circuitVersion"`
+	// Score is the percentage of specified truth table rows the circuit gets right.
+	Score float64 `json:"score"`
+	// Passed is set when every row is right and no constraint is violated.
+	Passed      bool                   `json:"passed"`
+	RowsChecked int32                  `json:"rowsChecked"`
+	RowsFailed  int32                  `json:"rowsFailed"`
+	FailingRows []*FailingRow          `json:"failingRows"`
+	Violations  []*ConstraintViolation `json:"violations"`
+	GateCount   int32                  `json:"gateCount"`
+	// Depth is the largest number of gates on a path from an input to an output.
+	Depth       int32     `json:"depth"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// Check reports an error if the exercise is not well-defined. A reference circuit is
+// checked separately, with CheckReference, before its truth table is computed.
+func (e *Exercise) Check() error {
+	if e.Title == "" {
+		return fmt.Errorf("exercise title cannot be empty")
+	}
+	if len(e.Inputs) == 0 || len(e.Outputs) == 0 {
+		return fmt.Errorf("exercise needs at least one input and one output")
+	}
+	if len(e.Inputs) > maxExerciseInputs {
+		return fmt.Errorf("exercise supports at most %d inputs, got %d", maxExerciseInputs, len(e.Inputs))
+	}
+	for kind, titles := range map[string][]string{"input": e.Inputs, "output": e.Outputs} {
+		seen := make(map[string]bool)
+		for _, title := range titles {
+			if title == "" {
+				return fmt.Errorf("exercise %s titles cannot be empty", kind)
+			}
+			if seen[title] {
+				return fmt.Errorf("duplicate exercise %s title '%s'", kind, title)
+			}
+			seen[title] = true
+		}
+	}
+
+	if (len(e.TruthTable) == 0) == (e.ReferenceCircuitID == "") {
+		return fmt.Errorf("exercise needs either a truth table or a reference circuit")
+	}
+	rows := make(map[string]bool)
+	for i, row := range e.TruthTable {
+		if len(row.Inputs) != len(e.Inputs) || len(row.Outputs) != len(e.Outputs) {
+			return fmt.Errorf("truth table row %d needs %d inputs and %d outputs", i+1, len(e.Inputs), len(e.Outputs))
+		}
+		key := truthTableKey(row.Inputs)
+		if rows[key] {
+			return fmt.Errorf("truth table row %d repeats the inputs of an earlier row", i+1)
+		}
+		rows[key] = true
+	}
+
+	for _, nodeType := range e.AllowedGateTypes {
+		if !nodeType.IsValid() || !isGateType(nodeType) {
+			return fmt.Errorf("%s is not a gate type", nodeType)
+		}
+	}
+	if e.MaxGates != nil && *e.MaxGates < 0 {
+		return fmt.Errorf("maximum gate count cannot be negative")
+	}
+	if e.MaxDepth != nil && *e.MaxDepth < 0 {
+		return fmt.Errorf("maximum depth cannot be negative")
+	}
+	return nil
+}
+
+// CheckReference reports an error if reference does not have the exercise's
+// interface or is not combinational.
+func (e *Exercise) CheckReference(reference *Circuit) error {
+	if violations, _ := e.interfaceViolations(reference); len(violations) > 0 {
+		return fmt.Errorf("reference circuit: %s", violations[0].Message)
+	}
+	for _, node := range reference.Nodes {
+		if isStateNode(node) {
+			return fmt.Errorf("reference circuit must be combinational")
+		}
+	}
+	if _, err := reference.Validate(); err != nil {
+		return fmt.Errorf("reference circuit: %w", err)
+	}
+	return nil
+}
+
+// ReferenceTruthTable evaluates a flattened reference circuit for every combination
+// of the exercise's inputs. Outputs the reference leaves unknown are don't-cares, and
+// rows without a known output are left out.
+func (e *Exercise) ReferenceTruthTable(ctx context.Context, reference *Circuit) ([]*TruthTableRow, error) {
+	validated, err := reference.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("reference circuit: %w", err)
+	}
+
+	table := []*TruthTableRow{}
+	for bits := 0; bits < 1<<len(e.Inputs); bits++ {
+		values, inputs := e.inputRow(bits)
+		result, err := validated.Evaluate(ctx, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate reference circuit: %w", err)
+		}
+
+		row := &TruthTableRow{Inputs: values, Outputs: make([]*bool, len(e.Outputs))}
+		known := false
+		for _, output := range result.Outputs {
+			i := slices.Index(e.Outputs, output.Title)
+			if i >= 0 && (output.State == LogicFalse || output.State == LogicTrue) {
+				row.Outputs[i] = output.State.Bool()
+				known = true
+			}
+		}
+		if known {
+			table = append(table, row)
+		}
+	}
+	return table, nil
+}
+
+// Grade checks a flattened circuit against the exercise's truth table for every
+// combination of inputs, and against its constraints.
+func (e *Exercise) Grade(ctx context.Context, circuit *Circuit) (*ExerciseSubmission, error) {
+	submission := &ExerciseSubmission{
+		ExerciseID:     e.ID,
+		CircuitID:      circuit.ID,
+		CircuitVersion: circuit.Version,
+		FailingRows:    []*FailingRow{},
+	}
+	violate := func(kind ViolationKind, format string, args ...interface{}) {
+		submission.Violations = append(submission.Violations, &ConstraintViolation{Kind: kind, Message: fmt.Sprintf(format, args...)})
+	}
+	// Unexpected inputs and outputs are violations, but only missing or shared ones prevent grading.
+	var gradable bool
+	submission.Violations, gradable = e.interfaceViolations(circuit)
+	combinational := true
+
+	// Gate types and count
+	used := make(map[NodeType]int)
+	for _, node := range circuit.Nodes {
+		if nodeType := NodeTypeOf(node); isGateType(nodeType) {
+			used[nodeType]++
+			submission.GateCount++
+		}
+		if isStateNode(node) {
+			combinational = false
+		}
+	}
+	if !combinational {
+		violate(ViolationStructure, "circuit must be combinational, without flip-flops or latches")
+		gradable = false
+	}
+	if len(e.AllowedGateTypes) > 0 {
+		allowed := make(map[NodeType]bool)
+		for _, nodeType := range e.AllowedGateTypes {
+			allowed[nodeType] = true
+		}
+		var types []string
+		for nodeType := range used {
+			if !allowed[nodeType] {
+				types = append(types, string(nodeType))
+			}
+		}
+		sort.Strings(types)
+		for _, nodeType := range types {
+			violate(ViolationGateType, "gate type %s is not allowed but used by %d nodes", nodeType, used[NodeType(nodeType)])
+		}
+	}
+	if e.MaxGates != nil && submission.GateCount > *e.MaxGates {
+		violate(ViolationGateCount, "circuit uses %d gates, at most %d are allowed", submission.GateCount, *e.MaxGates)
+	}
+
+	validated, err := circuit.Validate()
+	if err != nil {
+		violate(ViolationStructure, "%v", err)
+		gradable = false
+	} else {
+		submission.Depth = gateDepth(validated.graph)
+		if e.MaxDepth != nil && submission.Depth > *e.MaxDepth {
+			violate(ViolationDepth, "circuit is %d gates deep, at most %d are allowed", submission.Depth, *e.MaxDepth)
+		}
+	}
+
+	if gradable {
+		if err := e.gradeRows(ctx, validated, submission); err != nil {
+			return nil, err
+		}
+	}
+	if submission.RowsChecked > 0 {
+		passedRows := submission.RowsChecked - submission.RowsFailed
+		submission.Score = 100 * float64(passedRows) / float64(submission.RowsChecked)
+	} else if gradable {
+		submission.Score = 100
+	}
+	submission.Passed = gradable && submission.RowsFailed == 0 && len(submission.Violations) == 0
+	return submission, nil
+}
+
+// gradeRows evaluates every row of the truth table and records the failing ones.
+func (e *Exercise) gradeRows(ctx context.Context, validated *ValidatedCircuit, submission *ExerciseSubmission) error {
+	table := make(map[string]*TruthTableRow)
+	for _, row := range e.TruthTable {
+		table[truthTableKey(row.Inputs)] = row
+	}
+
+	for bits := 0; bits < 1<<len(e.Inputs); bits++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, inputs := e.inputRow(bits)
+
+		expected := make(map[string]LogicValue)
+		if row, ok := table[truthTableKey(values)]; ok {
+			for j, title := range e.Outputs {
+				if row.Outputs[j] != nil {
+					expected[title] = LogicFromBool(*row.Outputs[j])
+				}
+			}
+		}
+		if len(expected) == 0 {
+			continue
+		}
+
+		result, err := validated.Evaluate(ctx, inputs)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate circuit: %w", err)
+		}
+		submission.RowsChecked++
+		mismatches := DiffOutputs(result.Outputs, expected)
+		if len(mismatches) == 0 {
+			continue
+		}
+		submission.RowsFailed++
+		if len(submission.FailingRows) < maxFailingRows {
+			row := &FailingRow{Inputs: make([]*NodeOutput, len(e.Inputs)), Mismatches: mismatches}
+			for i, title := range e.Inputs {
+				value := LogicFromBool(values[i])
+				row.Inputs[i] = &NodeOutput{NodeID: validated.graph.inputsByTitle[title], Title: title, Value: value == LogicTrue, State: value}
+			}
+			submission.FailingRows = append(submission.FailingRows, row)
+		}
+	}
+	return nil
+}
+
+// inputRow returns the input values of the given row of a truth table over the
+// exercise's inputs, with the first input as the most significant bit.
+func (e *Exercise) inputRow(bits int) ([]bool, []*InputNodeValue) {
+	values := make([]bool, len(e.Inputs))
+	inputs := make([]*InputNodeValue, len(e.Inputs))
+	for i, title := range e.Inputs {
+		values[i] = bits&(1<<(len(e.Inputs)-1-i)) != 0
+		inputs[i] = &InputNodeValue{Title: title, Value: &values[i]}
+	}
+	return values, inputs
+}
+
+// interfaceViolations compares the titled inputs and outputs of c with the exercise's,
+// reporting whether c has every one the exercise asks for, each under a title no
+// other input or output shares.
+func (e *Exercise) interfaceViolations(c *Circuit) ([]*ConstraintViolation, bool) {
+	complete := true
+	violations := []*ConstraintViolation{}
+	for _, kind := range []string{"input", "output"} {
+		want := e.Inputs
+		if kind == "output" {
+			want = e.Outputs
+		}
+
+		have := make(map[string]bool)
+		var extra, shared []string
+		for _, node := range c.Nodes {
+			var title string
+			switch n := node.(type) {
+			case *InputNode:
+				if kind != "input" {
+					continue
+				}
+				title = n.Title
+			case *OutputNode:
+				if kind != "output" {
+					continue
+				}
+				title = n.Title
+			default:
+				continue
+			}
+			if have[title] && containsString(want, title) && !containsString(shared, title) {
+				shared = append(shared, title)
+			}
+			have[title] = true
+			if !containsString(want, title) {
+				if title == "" {
+					title = "(untitled " + node.GetID() + ")"
+				}
+				extra = append(extra, title)
+			}
+		}
+
+		var missing []string
+		for _, title := range want {
+			if !have[title] {
+				missing = append(missing, title)
+			}
+		}
+		if len(missing) > 0 {
+			complete = false
+			violations = append(violations, &ConstraintViolation{
+				Kind:    ViolationInterface,
+				Message: fmt.Sprintf("missing %ss: %s", kind, strings.Join(missing, ", ")),
+			})
+		}
+		if len(extra) > 0 {
+			violations = append(violations, &ConstraintViolation{
+				Kind:    ViolationInterface,
+				Message: fmt.Sprintf("unexpected %ss: %s", kind, strings.Join(extra, ", ")),
+			})
+		}
+		// Inputs and outputs are matched by title
+		if len(shared) > 0 {
+			complete = false
+			violations = append(violations, &ConstraintViolation{
+				Kind:    ViolationInterface,
+				Message: fmt.Sprintf("several %ss titled %s", kind, strings.Join(shared, ", ")),
+			})
+		}
+	}
+	return violations, complete
+}
+
+// isGateType reports whether nodes of the type count as gates.
+func isGateType(nodeType NodeType) bool {
+	switch nodeType {
+	case NodeTypeAnd, NodeTypeOr, NodeTypeNot, NodeTypeTriState, NodeTypeDFF, NodeTypeSRLatch:
+		return true
+	}
+	return false
+}
+
+// gateDepth returns the largest number of gates on any path through an acyclic circuit.
+func gateDepth(graph *evaluationGraph) int32 {
+	depth := make(map[string]int32, len(graph.order))
+	var deepest int32
+	for _, nodeID := range graph.order {
+		var d int32
+		for _, source := range graph.incomingEdges[nodeID] {
+			d = max(d, depth[source])
+		}
+		if isGateType(NodeTypeOf(graph.nodeMap[nodeID])) {
+			d++
+		}
+		depth[nodeID] = d
+		deepest = max(deepest, d)
+	}
+	return deepest
+}
+
+func truthTableKey(inputs []bool) string {
+	key := make([]byte, len(inputs))
+	for i, value := range inputs {
+		key[i] = '0'
+		if value {
+			key[i] = '1'
+		}
+	}
+	return string(key)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReferenceTruthTableIsGradedAfterReferenceChanges(t *testing.T) {
+	ctx := context.Background()
+	and := buildCircuit("and", []Node{input("a", "A"), input("b", "B"), &AndNode{ID: "g"}, output("y", "Y")},
+		"a>g", "b>g", "g>y")
+	exercise := &Exercise{ID: "e", Title: "And", Inputs: []string{"A", "B"}, Outputs: []string{"Y"}, ReferenceCircuitID: "and"}
+
+	table, err := exercise.ReferenceTruthTable(ctx, and)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 4 {
+		t.Fatalf("got %d rows, want 4", len(table))
+	}
+	for _, row := range table {
+		want := row.Inputs[0] && row.Inputs[1]
+		if row.Outputs[0] == nil || *row.Outputs[0] != want {
+			t.Errorf("row %v: got %v, want %v", row.Inputs, row.Outputs[0], want)
+		}
+	}
+	exercise.TruthTable = table
+
+	// Changing the reference afterwards does not change what the exercise asks for.
+	and.Nodes[2] = &OrNode{ID: "g"}
+	submission, err := exercise.Grade(ctx, buildCircuit("solution",
+		[]Node{input("a", "A"), input("b", "B"), &AndNode{ID: "g"}, output("y", "Y")}, "a>g", "b>g", "g>y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !submission.Passed || submission.RowsChecked != 4 {
+		t.Errorf("got passed %v with %d rows checked, want a pass over 4 rows", submission.Passed, submission.RowsChecked)
+	}
+}
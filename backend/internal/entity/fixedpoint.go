@@ -0,0 +1,273 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// maxFixedPointIterations caps the iterations of a single fixed-point evaluation.
+const maxFixedPointIterations = 10000
+
+// FixedPointStatus is the outcome of a fixed-point evaluation.
+type FixedPointStatus string
+
+const (
+	// FixedPointStable means another iteration would not change any value.
+	FixedPointStable FixedPointStatus = "STABLE"
+	// FixedPointOscillating means the values repeat with a period of two or more iterations.
+	FixedPointOscillating FixedPointStatus = "OSCILLATING"
+	// FixedPointNotConverged means no value assignment repeated within the iteration limit.
+	FixedPointNotConverged FixedPointStatus = "NOT_CONVERGED"
+)
+
+func (s FixedPointStatus) IsValid() bool {
+	switch s {
+	case FixedPointStable, FixedPointOscillating, FixedPointNotConverged:
+		return true
+	}
+	return false
+}
+
+func (s FixedPointStatus) String() string {
+	return string(s)
+}
+
+func (s *FixedPointStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*s = FixedPointStatus(str)
+	if !s.IsValid() {
+		return fmt.Errorf("%s is not a valid FixedPointStatus", str)
+	}
+	return nil
+}
+
+func (s FixedPointStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(s.String()))
+}
+
+// NodeValue sets the value of any node, such as the initial state of a fixed-point
+// evaluation. A nil Value is unknown.
+type NodeValue struct {
+	NodeID string `json:"nodeID"`
+	Value  *bool  `json:"value"`
+}
+
+// FixedPointResult is the outcome of EvaluateFixedPoint.
+type FixedPointResult struct {
+	Status FixedPointStatus `json:"status"`
+	// Iterations is the number of iterations performed.
+	Iterations int32 `json:"iterations"`
+	// Period is the number of iterations after which values repeat, for an oscillation.
+	Period *int32 `json:"period"`
+	// Outputs are the values after the last iteration, ordered by title and then ID.
+	Outputs      []*NodeOutput `json:"outputs"`
+	NamedOutputs NamedOutputs  `json:"namedOutputs"`
+	// OscillatingNodeIDs lists the nodes whose value changes within an oscillation.
+	OscillatingNodeIDs []string `json:"oscillatingNodeIDs"`
+}
+
+// EvaluateFixedPoint evaluates a circuit that may contain combinational cycles by
+// iterating from an initial state until the values repeat. Unlike EvaluateCircuit
+// it does not reject cycles.
+//
+// Every iteration updates all nodes at once from the values of the previous
+// iteration. Nodes start out with the value given in initial, or false; inputs
+// that are not given are unknown. Flip-flops and latches hold their initial value.
+func (c *Circuit) EvaluateFixedPoint(ctx context.Context, inputs []*InputNodeValue, initial []*NodeValue, maxIterations int) (*FixedPointResult, error) {
+	if maxIterations < 1 || maxIterations > maxFixedPointIterations {
+		return nil, fmt.Errorf("maxIterations must be between 1 and %d, got %d", maxFixedPointIterations, maxIterations)
+	}
+	if err := c.checkStructure(); err != nil {
+		return nil, err
+	}
+	graph := c.newEvaluationGraph()
+
+	index := make(map[string]int, len(c.Nodes))
+	for i, node := range c.Nodes {
+		index[node.GetID()] = i
+	}
+	sources := make([][]int, len(c.Nodes))
+	for i, node := range c.Nodes {
+		for _, sourceID := range graph.incomingEdges[node.GetID()] {
+			sources[i] = append(sources[i], index[sourceID])
+		}
+	}
+
+	current := make([]LogicValue, len(c.Nodes))
+	for _, value := range initial {
+		i, exists := index[value.NodeID]
+		if !exists {
+			return nil, fmt.Errorf("initial value given for non-existent node: %s", value.NodeID)
+		}
+		if value.Value == nil {
+			current[i] = LogicUnknown
+		} else {
+			current[i] = LogicFromBool(*value.Value)
+		}
+	}
+	for i, node := range c.Nodes {
+		if _, ok := node.(*InputNode); ok {
+			current[i] = LogicUnknown
+		}
+	}
+	for _, input := range inputs {
+		nodeID, err := graph.inputNodeID(input)
+		if err != nil {
+			return nil, err
+		}
+		current[index[nodeID]] = input.state()
+	}
+
+	initialValues := current
+	var scratch []LogicValue
+	// step performs one iteration, returning the values of every node after it.
+	step := func(current []LogicValue) ([]LogicValue, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		next := make([]LogicValue, len(c.Nodes))
+		for i, node := range c.Nodes {
+			if _, ok := node.(*InputNode); ok || isStateNode(node) {
+				next[i] = current[i]
+				continue
+			}
+
+			scratch = scratch[:0]
+			for _, source := range sources[i] {
+				scratch = append(scratch, current[source])
+			}
+			value, err := c.evaluateNode(node, scratch, graph.incomingPorts[node.GetID()])
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate node %s: %w", node.GetID(), err)
+			}
+			next[i] = value
+		}
+		return next, nil
+	}
+	// advance performs n iterations from values.
+	advance := func(values []LogicValue, n int) ([]LogicValue, error) {
+		var err error
+		for ; n > 0 && err == nil; n-- {
+			values, err = step(values)
+		}
+		return values, err
+	}
+
+	// Brent's cycle detection finds the period without keeping past values. The
+	// tortoise waits at iterations 2^k-1 while the hare runs up to 2^k iterations
+	// ahead; if values repeat within maxIterations, the hare meets the tortoise
+	// within 3*maxIterations iterations.
+	period, power := 1, 1
+	tortoise := initialValues
+	hare, err := step(tortoise)
+	if err != nil {
+		return nil, err
+	}
+	for hareIteration := 1; !slices.Equal(tortoise, hare); hareIteration++ {
+		if hareIteration >= 3*maxIterations {
+			return c.notConverged(advance, initialValues, maxIterations)
+		}
+		if power == period {
+			tortoise = hare
+			power *= 2
+			period = 0
+		}
+		if hare, err = step(hare); err != nil {
+			return nil, err
+		}
+		period++
+	}
+
+	// The values first repeat after start+period iterations, where start is the
+	// first iteration of the cycle: walk from the beginning with a gap of period.
+	start := 0
+	tortoise = initialValues
+	if hare, err = advance(initialValues, period); err != nil {
+		return nil, err
+	}
+	for !slices.Equal(tortoise, hare) {
+		if tortoise, err = step(tortoise); err != nil {
+			return nil, err
+		}
+		if hare, err = step(hare); err != nil {
+			return nil, err
+		}
+		start++
+	}
+	if start+period > maxIterations {
+		return c.notConverged(advance, initialValues, maxIterations)
+	}
+
+	// Walk the cycle once more to find the nodes whose value changes in it.
+	oscillating := make([]bool, len(c.Nodes))
+	values := tortoise
+	for i := 1; i < period; i++ {
+		if values, err = step(values); err != nil {
+			return nil, err
+		}
+		for j := range values {
+			if values[j] != tortoise[j] {
+				oscillating[j] = true
+			}
+		}
+	}
+	return c.fixedPointResult(tortoise, period, oscillating, start+period)
+}
+
+// notConverged reports the values after maxIterations iterations from initial.
+func (c *Circuit) notConverged(advance func([]LogicValue, int) ([]LogicValue, error), initial []LogicValue, maxIterations int) (*FixedPointResult, error) {
+	current, err := advance(initial, maxIterations)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.fixedPointResult(current, 1, nil, maxIterations)
+	if err != nil {
+		return nil, err
+	}
+	result.Status = FixedPointNotConverged
+	return result, nil
+}
+
+// fixedPointResult reports the values in current, which repeat every period
+// iterations. oscillating flags the nodes whose value changes within the period.
+func (c *Circuit) fixedPointResult(current []LogicValue, period int, oscillating []bool, iterations int) (*FixedPointResult, error) {
+	values := make(map[string]LogicValue)
+	for i, node := range c.Nodes {
+		if _, ok := node.(*OutputNode); ok {
+			values[node.GetID()] = current[i]
+		}
+	}
+	outputs, err := c.collectOutputs(values)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := successfulResult(outputs)
+	result := &FixedPointResult{
+		Status:             FixedPointStable,
+		Iterations:         int32(iterations),
+		Outputs:            evaluation.Outputs,
+		NamedOutputs:       evaluation.NamedOutputs,
+		OscillatingNodeIDs: []string{},
+	}
+
+	if period > 1 {
+		p := int32(period)
+		result.Status = FixedPointOscillating
+		result.Period = &p
+		for i, node := range c.Nodes {
+			if oscillating[i] {
+				result.OscillatingNodeIDs = append(result.OscillatingNodeIDs, node.GetID())
+			}
+		}
+	}
+	return result, nil
+}
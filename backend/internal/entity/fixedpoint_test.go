@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestEvaluateFixedPointFindsCycleAfterTransient(t *testing.T) {
+	// R oscillates from the start; G = R AND D3 only follows it once X has passed
+	// through the delay line D1..D3, so the values first repeat after 6 iterations.
+	c := buildCircuit("transient", []Node{input("x", "X"), &NotNode{ID: "r"}, &OrNode{ID: "d1"}, &OrNode{ID: "d2"}, &OrNode{ID: "d3"}, &AndNode{ID: "g"}, output("y", "Y")},
+		"r>r", "x>d1", "d1>d2", "d2>d3", "r>g", "d3>g", "g>y")
+	value := true
+	result, err := c.EvaluateFixedPoint(context.Background(), []*InputNodeValue{{Title: "X", Value: &value}}, nil, 100)
+	if err != nil {
+		t.Fatalf("EvaluateFixedPoint() error = %v", err)
+	}
+	if result.Status != FixedPointOscillating || result.Period == nil || *result.Period != 2 || result.Iterations != 6 {
+		t.Fatalf("status = %s, period = %v, iterations = %d, want OSCILLATING with period 2 after 6", result.Status, result.Period, result.Iterations)
+	}
+	if want := []string{"r", "g", "y"}; !slices.Equal(result.OscillatingNodeIDs, want) {
+		t.Errorf("oscillating nodes = %v, want %v", result.OscillatingNodeIDs, want)
+	}
+	if got := result.NamedOutputs["Y"]; got != LogicFalse {
+		t.Errorf("Y = %s, want FALSE", got)
+	}
+
+	limited, err := c.EvaluateFixedPoint(context.Background(), []*InputNodeValue{{Title: "X", Value: &value}}, nil, 5)
+	if err != nil {
+		t.Fatalf("EvaluateFixedPoint() error = %v", err)
+	}
+	if limited.Status != FixedPointNotConverged || limited.Iterations != 5 {
+		t.Errorf("with 5 iterations: status = %s, iterations = %d, want NOT_CONVERGED after 5", limited.Status, limited.Iterations)
+	}
+	if got := limited.NamedOutputs["Y"]; got != LogicTrue {
+		t.Errorf("with 5 iterations: Y = %s, want TRUE", got)
+	}
+}
+
+func TestEvaluateFixedPointIterationsAreMinimal(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(7))
+	for n := 0; n < 100; n++ {
+		// Gates read any gate, including themselves, and start from random values.
+		c := &Circuit{ID: "cyclic"}
+		var initial []*NodeValue
+		gates := 4 + r.Intn(8)
+		for g := 0; g < gates; g++ {
+			id := fmt.Sprintf("g%d", g)
+			fanIn := 2
+			switch r.Intn(3) {
+			case 0:
+				c.Nodes = append(c.Nodes, &AndNode{ID: id})
+			case 1:
+				c.Nodes = append(c.Nodes, &OrNode{ID: id})
+			default:
+				c.Nodes = append(c.Nodes, &NotNode{ID: id})
+				fanIn = 1
+			}
+			for i := 0; i < fanIn; i++ {
+				c.Edges = append(c.Edges, &Edge{ID: fmt.Sprintf("%s-%d", id, i), SourceNodeID: fmt.Sprintf("g%d", r.Intn(gates)), TargetNodeID: id})
+			}
+			value := r.Intn(2) == 1
+			initial = append(initial, &NodeValue{NodeID: id, Value: &value})
+		}
+
+		result, err := c.EvaluateFixedPoint(ctx, nil, initial, maxFixedPointIterations)
+		if err != nil {
+			t.Fatalf("circuit %d: EvaluateFixedPoint() error = %v", n, err)
+		}
+		if result.Status == FixedPointNotConverged {
+			t.Fatalf("circuit %d: not converged", n)
+		}
+		// One iteration fewer must not reach the repetition.
+		if result.Iterations > 1 {
+			limited, err := c.EvaluateFixedPoint(ctx, nil, initial, int(result.Iterations)-1)
+			if err != nil {
+				t.Fatalf("circuit %d: EvaluateFixedPoint() error = %v", n, err)
+			}
+			if limited.Status != FixedPointNotConverged {
+				t.Errorf("circuit %d: %s after %d iterations, but %s within %d", n, result.Status, result.Iterations, limited.Status, limited.Iterations)
+			}
+		}
+		// The values after start+period iterations repeat period iterations later.
+		if result.Period != nil {
+			again, err := c.EvaluateFixedPoint(ctx, nil, initial, int(result.Iterations+*result.Period))
+			if err != nil {
+				t.Fatalf("circuit %d: EvaluateFixedPoint() error = %v", n, err)
+			}
+			if again.Iterations != result.Iterations || !slices.Equal(again.OscillatingNodeIDs, result.OscillatingNodeIDs) {
+				t.Errorf("circuit %d: results differ with a higher limit", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// maxNestingDepth bounds how deeply CircuitNodes may be nested.
+	maxNestingDepth = 32
+	// maxFlattenedNodes bounds the size of a flattened circuit.
+	maxFlattenedNodes = 1 << 20
+)
+
+// CircuitLoader loads a circuit by ID, such as the circuit referenced by a CircuitNode.
+type CircuitLoader func(ctx context.Context, circuitID string) (*Circuit, error)
+
+// wireNode replaces the InputNodes and OutputNodes of nested circuits in a flattened
+// circuit. It passes its (bus-resolved) input through without delay.
+type wireNode struct {
+	ID    string
+	Title string
+}
+
+func (n *wireNode) GetID() string {
+	return n.ID
+}
+
+// FlatCircuit is a circuit in which every CircuitNode has been replaced by the nodes
+// of the circuit it references.
+//
+// Nodes and edges of the top-level circuit keep their IDs. Those of a nested circuit
+// are prefixed with the ID of their CircuitNode and a slash, e.g. "adder/carry".
+// Nested inputs and outputs become wires that keep their titles, and the edges that
+// connected to the CircuitNode connect to these wires instead.
+type FlatCircuit struct {
+	*Circuit
+	// scopes maps nested node IDs to the IDs of the enclosing CircuitNodes, outermost first.
+	scopes map[string][]string
+	// instances maps the ID of each replaced CircuitNode to the circuit it referenced.
+	instances map[string]*Circuit
+}
+
+// Scope returns the IDs of the CircuitNodes the node is nested in, outermost first.
+// It is empty for nodes of the top-level circuit.
+func (f *FlatCircuit) Scope(nodeID string) []string {
+	return f.scopes[nodeID]
+}
+
+// Instance returns the circuit referenced by a replaced CircuitNode, or nil.
+func (f *FlatCircuit) Instance(circuitNodeID string) *Circuit {
+	return f.instances[circuitNodeID]
+}
+
+// Nested reports whether any CircuitNode was replaced.
+func (f *FlatCircuit) Nested() bool {
+	return len(f.instances) > 0
+}
+
+// Flatten replaces every CircuitNode, recursively, by the nodes of the circuit it
+// references, loaded through load. Edges into and out of a CircuitNode are matched
+// to the referenced circuit's inputs and outputs by port (see CircuitPort).
+func (c *Circuit) Flatten(ctx context.Context, load CircuitLoader) (*FlatCircuit, error) {
+	if c == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+
+	f := &flattener{
+		load:   load,
+		loaded: make(map[string]*Circuit),
+		flat: &FlatCircuit{
+			Circuit:   &Circuit{ID: c.ID, Title: c.Title, Version: c.Version},
+			scopes:    make(map[string][]string),
+			instances: make(map[string]*Circuit),
+		},
+	}
+	if err := f.add(ctx, c, "", nil, []string{c.ID}); err != nil {
+		return nil, fmt.Errorf("failed to flatten circuit %s: %w", c.ID, err)
+	}
+	return f.flat, nil
+}
+
+type flattener struct {
+	load   CircuitLoader
+	loaded map[string]*Circuit
+	flat   *FlatCircuit
+}
+
+// add copies the nodes and edges of c into the flat circuit, prefixing their IDs.
+// scope lists the enclosing CircuitNodes and stack the IDs of the enclosing circuits.
+func (f *flattener) add(ctx context.Context, c *Circuit, prefix string, scope []string, stack []string) error {
+	if len(stack) > maxNestingDepth {
+		return fmt.Errorf("circuit nodes are nested more than %d levels deep", maxNestingDepth)
+	}
+
+	instances := make(map[string]*Circuit)
+	for _, node := range c.Nodes {
+		if node == nil {
+			return fmt.Errorf("circuit %s contains a nil node", c.ID)
+		}
+		id := prefix + node.GetID()
+
+		circuitNode, ok := node.(*CircuitNode)
+		if !ok {
+			if len(f.flat.Nodes) >= maxFlattenedNodes {
+				return fmt.Errorf("flattened circuit exceeds %d nodes", maxFlattenedNodes)
+			}
+			f.flat.Nodes = append(f.flat.Nodes, f.copyNode(node, id, prefix != ""))
+			if len(scope) > 0 {
+				f.flat.scopes[id] = scope
+			}
+			continue
+		}
+
+		referenced, err := f.referenced(ctx, circuitNode)
+		if err != nil {
+			return err
+		}
+		for _, circuitID := range stack {
+			if circuitID == referenced.ID {
+				return fmt.Errorf("circuit node %s references circuit %s, which contains it", circuitNode.ID, referenced.ID)
+			}
+		}
+		instances[node.GetID()] = referenced
+		f.flat.instances[id] = referenced
+
+		nestedScope := append(append([]string{}, scope...), id)
+		if err := f.add(ctx, referenced, id+"/", nestedScope, append(stack, referenced.ID)); err != nil {
+			return err
+		}
+	}
+
+	for _, edge := range c.Edges {
+		flatEdge := &Edge{
+			ID:           prefix + edge.ID,
+			SourceNodeID: prefix + edge.SourceNodeID,
+			TargetNodeID: prefix + edge.TargetNodeID,
+			TargetPort:   edge.TargetPort,
+		}
+		if referenced, ok := instances[edge.SourceNodeID]; ok {
+			port, err := CircuitPort(referenced, edge.SourcePort, false)
+			if err != nil {
+				return fmt.Errorf("edge %s: %w", edge.ID, err)
+			}
+			flatEdge.SourceNodeID = prefix + edge.SourceNodeID + "/" + port.GetID()
+		}
+		if referenced, ok := instances[edge.TargetNodeID]; ok {
+			port, err := CircuitPort(referenced, edge.TargetPort, true)
+			if err != nil {
+				return fmt.Errorf("edge %s: %w", edge.ID, err)
+			}
+			flatEdge.TargetNodeID = prefix + edge.TargetNodeID + "/" + port.GetID()
+			flatEdge.TargetPort = ""
+		}
+		f.flat.Edges = append(f.flat.Edges, flatEdge)
+	}
+	return nil
+}
+
+// referenced loads the circuit a CircuitNode references, once per circuit.
+func (f *flattener) referenced(ctx context.Context, circuitNode *CircuitNode) (*Circuit, error) {
+	if circuitNode.Circuit == nil || circuitNode.Circuit.ID == "" {
+		return nil, fmt.Errorf("circuit node %s does not reference a circuit", circuitNode.ID)
+	}
+	circuitID := circuitNode.Circuit.ID
+	if circuit, ok := f.loaded[circuitID]; ok {
+		return circuit, nil
+	}
+	if f.load == nil {
+		return nil, fmt.Errorf("cannot load circuit %s referenced by circuit node %s", circuitID, circuitNode.ID)
+	}
+
+	circuit, err := f.load(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load circuit %s: %w", circuitID, err)
+	}
+	f.loaded[circuitID] = circuit
+	return circuit, nil
+}
+
+// copyNode copies node under a new ID. Inputs and outputs of nested circuits become wires.
+func (f *flattener) copyNode(node Node, id string, nested bool) Node {
+	switch n := node.(type) {
+	case *InputNode:
+		if nested {
+			return &wireNode{ID: id, Title: n.Title}
+		}
+		return &InputNode{ID: id, Title: n.Title}
+	case *OutputNode:
+		if nested {
+			return &wireNode{ID: id, Title: n.Title}
+		}
+		return &OutputNode{ID: id, Title: n.Title}
+	case *AndNode:
+		return &AndNode{ID: id}
+	case *OrNode:
+		return &OrNode{ID: id}
+	case *NotNode:
+		return &NotNode{ID: id}
+	case *TriStateNode:
+		return &TriStateNode{ID: id}
+	case *DFlipFlopNode:
+		return &DFlipFlopNode{ID: id, Title: n.Title}
+	case *SRLatchNode:
+		return &SRLatchNode{ID: id, Title: n.Title}
+	}
+	return node
+}
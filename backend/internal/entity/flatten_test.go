@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// loaderOf loads the given circuits by ID.
+func loaderOf(circuits ...*Circuit) CircuitLoader {
+	return func(ctx context.Context, circuitID string) (*Circuit, error) {
+		for _, c := range circuits {
+			if c.ID == circuitID {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("circuit %s not found", circuitID)
+	}
+}
+
+func TestFlattenPortlessEdges(t *testing.T) {
+	ctx := context.Background()
+	inverter := buildCircuit("inverter", []Node{input("a", "A"), &NotNode{ID: "n"}, output("y", "Y")}, "a>n", "n>y")
+	gate := buildCircuit("gate", []Node{input("a", "A"), input("b", "B"), &AndNode{ID: "g"}, output("y", "Y")}, "a>g", "b>g", "g>y")
+
+	// Edges saved before ports existed connect to a circuit node without a port.
+	c := buildCircuit("top", []Node{input("x", "X"), &CircuitNode{ID: "inv", Circuit: &Circuit{ID: "inverter"}}, output("z", "Z")},
+		"x>inv", "inv>z")
+	flat, err := c.Flatten(ctx, loaderOf(inverter))
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	validated, err := flat.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	for _, x := range []bool{false, true} {
+		result, err := validated.Evaluate(ctx, []*InputNodeValue{{Title: "X", Value: &x}})
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if got, want := result.NamedOutputs["Z"], LogicFromBool(!x); got != want {
+			t.Errorf("X = %v: Z = %s, want %s", x, got, want)
+		}
+	}
+
+	// With several inputs, the port must say which one an edge feeds.
+	c = buildCircuit("top", []Node{input("x", "X"), &CircuitNode{ID: "and", Circuit: &Circuit{ID: "gate"}}, output("z", "Z")},
+		"x>and", "and>z")
+	if _, err := c.Flatten(ctx, loaderOf(gate)); err == nil || !strings.Contains(err.Error(), "port must name one") {
+		t.Errorf("Flatten() error = %v, want one asking for a port", err)
+	}
+}
@@ -0,0 +1,302 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// maxHazardInputs bounds the inputs of a circuit checked for hazards, as every
+	// combination of the other inputs is tried for each input transition.
+	maxHazardInputs = 12
+	// maxHazards caps the hazards reported by a single analysis.
+	maxHazards = 1000
+	// maxHazardPaths caps the paths reported per hazard.
+	maxHazardPaths = 16
+)
+
+// HazardKind classifies a hazard.
+type HazardKind string
+
+const (
+	// HazardStatic1 means an output that should stay true can briefly become false.
+	HazardStatic1 HazardKind = "STATIC_1"
+	// HazardStatic0 means an output that should stay false can briefly become true.
+	HazardStatic0 HazardKind = "STATIC_0"
+	// HazardDynamic means an output that should change once changes several times.
+	HazardDynamic HazardKind = "DYNAMIC"
+)
+
+func (k HazardKind) IsValid() bool {
+	switch k {
+	case HazardStatic1, HazardStatic0, HazardDynamic:
+		return true
+	}
+	return false
+}
+
+func (k HazardKind) String() string {
+	return string(k)
+}
+
+func (k *HazardKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*k = HazardKind(str)
+	if !k.IsValid() {
+		return fmt.Errorf("%s is not a valid HazardKind", str)
+	}
+	return nil
+}
+
+func (k HazardKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(k.String()))
+}
+
+// Hazard is a glitch an output may show when a single input changes.
+type Hazard struct {
+	Kind       HazardKind `json:"kind"`
+	InputID    string     `json:"inputID"`
+	InputTitle string     `json:"inputTitle"`
+	From       bool       `json:"from"`
+	To         bool       `json:"to"`
+	// Inputs are the values of all inputs before the transition, ordered by title and then ID.
+	Inputs      []*NodeOutput `json:"inputs"`
+	OutputID    string        `json:"outputID"`
+	OutputTitle string        `json:"outputTitle"`
+	// Paths are the reconvergent paths along which the transition reaches the output,
+	// as node IDs from the input to the output.
+	Paths [][]string `json:"paths"`
+	// Waveform lists the output's changes when every gate has a delay of 1. It is
+	// empty for static hazards that only appear with other delays.
+	Waveform []*ValueChange `json:"waveform"`
+}
+
+// HazardReport is the outcome of DetectHazards.
+type HazardReport struct {
+	Hazards []*Hazard `json:"hazards"`
+	// Truncated is set when more hazards were found than reported.
+	Truncated bool `json:"truncated"`
+}
+
+// DetectHazards finds static-1, static-0 and dynamic hazards of a combinational
+// circuit for every single-input transition, with every combination of values of
+// the other inputs. CircuitNodes must be flattened first (see Flatten).
+//
+// Only outputs reached from the changing input along two or more paths can glitch.
+// A static hazard exists if the output is unknown in three-valued simulation while
+// the input is in transition (the input is X); this holds for any gate delays.
+// Dynamic hazards are found by simulating the transition with unit gate delays,
+// which also provides the waveform of every hazard it makes visible.
+func (c *Circuit) DetectHazards(ctx context.Context) (*HazardReport, error) {
+	validated, err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	graph := validated.graph
+
+	var inputs, outputs []*NodeOutput
+	for _, node := range c.Nodes {
+		switch n := node.(type) {
+		case *InputNode:
+			inputs = append(inputs, &NodeOutput{NodeID: n.ID, Title: n.Title})
+		case *OutputNode:
+			outputs = append(outputs, &NodeOutput{NodeID: n.ID, Title: n.Title})
+		case *DFlipFlopNode, *SRLatchNode:
+			return nil, fmt.Errorf("hazard detection requires a combinational circuit, but node %s is a flip-flop or latch", n.GetID())
+		}
+	}
+	if len(inputs) > maxHazardInputs {
+		return nil, fmt.Errorf("hazard detection supports at most %d inputs, circuit has %d", maxHazardInputs, len(inputs))
+	}
+	sortNodeOutputs(inputs)
+	sortNodeOutputs(outputs)
+
+	delays, err := c.nodeDelays(TimingOptions{})
+	if err != nil {
+		return nil, err
+	}
+	paths := countPaths(graph, inputs)
+
+	report := &HazardReport{Hazards: []*Hazard{}}
+	for i, input := range inputs {
+		// Structurally, only outputs reached along several paths can glitch.
+		var candidates []*NodeOutput
+		for _, output := range outputs {
+			if paths[output.NodeID][i] >= 2 {
+				candidates = append(candidates, output)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		for others := 0; others < 1<<(len(inputs)-1); others++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			vector := func(value LogicValue) []*InputNodeValue {
+				values := make([]*InputNodeValue, len(inputs))
+				for j, in := range inputs {
+					state := value
+					if j != i {
+						bit := j
+						if j > i {
+							bit--
+						}
+						state = LogicFromBool(others&(1<<bit) != 0)
+					}
+					values[j] = &InputNodeValue{NodeID: in.NodeID, Value: state.Bool()}
+				}
+				return values
+			}
+
+			inTransition, err := c.computeValues(ctx, graph, vector(LogicUnknown), nil)
+			if err != nil {
+				return nil, err
+			}
+			var active []*NodeOutput
+			for _, output := range candidates {
+				if inTransition[output.NodeID] == LogicUnknown {
+					active = append(active, output)
+				}
+			}
+			if len(active) == 0 {
+				continue
+			}
+
+			for _, from := range []bool{false, true} {
+				before, err := c.computeValues(ctx, graph, vector(LogicFromBool(from)), nil)
+				if err != nil {
+					return nil, err
+				}
+				after, err := c.computeValues(ctx, graph, vector(LogicFromBool(!from)), nil)
+				if err != nil {
+					return nil, err
+				}
+				waveforms, err := c.unitDelayTransition(ctx, graph, delays, before, input.NodeID, LogicFromBool(!from))
+				if err != nil {
+					return nil, err
+				}
+
+				for _, output := range active {
+					start, end := before[output.NodeID], after[output.NodeID]
+					if start.Bool() == nil || end.Bool() == nil {
+						continue // Not a Boolean output, e.g. a floating bus
+					}
+
+					var kind HazardKind
+					switch {
+					case start == end && start == LogicTrue:
+						kind = HazardStatic1
+					case start == end:
+						kind = HazardStatic0
+					case len(waveforms[output.NodeID]) > 1:
+						kind = HazardDynamic
+					default:
+						continue
+					}
+
+					if len(report.Hazards) >= maxHazards {
+						report.Truncated = true
+						return report, nil
+					}
+					hazard := &Hazard{
+						Kind:        kind,
+						InputID:     input.NodeID,
+						InputTitle:  input.Title,
+						From:        from,
+						To:          !from,
+						Inputs:      make([]*NodeOutput, len(inputs)),
+						OutputID:    output.NodeID,
+						OutputTitle: output.Title,
+						Paths:       activePaths(graph, inTransition, input.NodeID, output.NodeID),
+						Waveform:    waveforms[output.NodeID],
+					}
+					if hazard.Waveform == nil {
+						hazard.Waveform = []*ValueChange{}
+					}
+					for j, in := range inputs {
+						value := before[in.NodeID]
+						hazard.Inputs[j] = &NodeOutput{NodeID: in.NodeID, Title: in.Title, Value: value == LogicTrue, State: value}
+					}
+					report.Hazards = append(report.Hazards, hazard)
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// countPaths counts, for every node, the paths from each input to it, saturating at 2.
+func countPaths(graph *evaluationGraph, inputs []*NodeOutput) map[string][]int {
+	counts := make(map[string][]int, len(graph.order))
+	for _, nodeID := range graph.order {
+		counts[nodeID] = make([]int, len(inputs))
+	}
+	for i, input := range inputs {
+		counts[input.NodeID][i] = 1
+	}
+	for _, nodeID := range graph.order {
+		for _, source := range graph.incomingEdges[nodeID] {
+			for i, count := range counts[source] {
+				counts[nodeID][i] = min(counts[nodeID][i]+count, 2)
+			}
+		}
+	}
+	return counts
+}
+
+// unitDelayTransition changes an input of a circuit settled at values and returns
+// the resulting value changes of every output.
+func (c *Circuit) unitDelayTransition(ctx context.Context, graph *evaluationGraph, delays map[string]int32, values map[string]LogicValue, inputID string, value LogicValue) (map[string][]*ValueChange, error) {
+	sim := newTimingSimulator(c, graph, delays)
+	for nodeID, v := range values {
+		sim.values[nodeID] = v
+	}
+	sim.schedule(0, inputID, value)
+
+	waveforms := make(map[string][]*ValueChange)
+	_, _, err := sim.run(ctx, math.MaxInt32, func(change *ValueChange) {
+		if _, ok := graph.nodeMap[change.NodeID].(*OutputNode); ok {
+			waveforms[change.NodeID] = append(waveforms[change.NodeID], change)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return waveforms, nil
+}
+
+// activePaths lists the paths from input to output that only pass through nodes that
+// are unknown while the input is in transition, i.e. that the transition can affect.
+func activePaths(graph *evaluationGraph, inTransition map[string]LogicValue, inputID, outputID string) [][]string {
+	paths := [][]string{}
+	var walk func(nodeID string, suffix []string)
+	walk = func(nodeID string, suffix []string) {
+		if len(paths) >= maxHazardPaths {
+			return
+		}
+		path := append([]string{nodeID}, suffix...)
+		if nodeID == inputID {
+			paths = append(paths, path)
+			return
+		}
+		seen := make(map[string]bool)
+		for _, source := range graph.incomingEdges[nodeID] {
+			if seen[source] || inTransition[source] != LogicUnknown {
+				continue
+			}
+			seen[source] = true
+			walk(source, path)
+		}
+	}
+	walk(outputID, nil)
+	return paths
+}
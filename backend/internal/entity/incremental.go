@@ -0,0 +1,134 @@
+package entity
+
+import (
+	"container/heap"
+	"context"
+)
+
+// EvaluationSession keeps the value of every node of a circuit between evaluations.
+// Changing inputs through SetInputs only re-evaluates the transitive fan-out of the
+// inputs that actually changed, and propagation stops at nodes whose value stays the same.
+//
+// An EvaluationSession is bound to the circuit structure it was created from; build
+// a new one after the circuit changes. It is not safe for concurrent use.
+type EvaluationSession struct {
+	circuit *Circuit
+	graph   *evaluationGraph
+	// outgoingEdges maps each node to the nodes it feeds into.
+	outgoingEdges map[string][]string
+	// position is each node's index in graph.order, used to process changes in order.
+	position map[string]int
+	values   map[string]LogicValue
+}
+
+// NewEvaluationSession validates the circuit and fully evaluates it once with inputs.
+func (c *Circuit) NewEvaluationSession(ctx context.Context, inputs []*InputNodeValue) (*EvaluationSession, error) {
+	validated, err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	graph := validated.graph
+
+	values, err := c.computeValues(ctx, graph, inputs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &EvaluationSession{
+		circuit:       c,
+		graph:         graph,
+		outgoingEdges: make(map[string][]string),
+		position:      make(map[string]int, len(graph.order)),
+		values:        values,
+	}
+	for i, nodeID := range graph.order {
+		session.position[nodeID] = i
+	}
+	for target, sources := range graph.incomingEdges {
+		for _, source := range sources {
+			session.outgoingEdges[source] = append(session.outgoingEdges[source], target)
+		}
+	}
+
+	return session, nil
+}
+
+// Result returns the current output values.
+func (s *EvaluationSession) Result() (*EvaluationResult, error) {
+	outputs, err := s.circuit.collectOutputs(s.values)
+	if err != nil {
+		return failedResult(err)
+	}
+	return successfulResult(outputs), nil
+}
+
+// SetInputs updates the given inputs, keeping the previous value of any input not
+// mentioned, and re-evaluates only the nodes affected by the change.
+func (s *EvaluationSession) SetInputs(ctx context.Context, inputs []*InputNodeValue) (*EvaluationResult, error) {
+	// Resolve everything up front so a bad input leaves the session untouched.
+	nodeIDs := make([]string, len(inputs))
+	for i, input := range inputs {
+		nodeID, err := s.graph.inputNodeID(input)
+		if err != nil {
+			return failedResult(err)
+		}
+		nodeIDs[i] = nodeID
+	}
+
+	// Pending nodes are processed in topological order, so each one is recomputed
+	// at most once and only after all of its changed sources.
+	pending := &positionHeap{}
+	queued := make(map[string]bool)
+	enqueueFanOut := func(nodeID string) {
+		for _, target := range s.outgoingEdges[nodeID] {
+			if !queued[target] {
+				queued[target] = true
+				heap.Push(pending, s.position[target])
+			}
+		}
+	}
+
+	for i, input := range inputs {
+		nodeID := nodeIDs[i]
+		value := input.state()
+		if old, exists := s.values[nodeID]; exists && old == value {
+			continue
+		}
+		s.values[nodeID] = value
+		enqueueFanOut(nodeID)
+	}
+
+	for pending.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return failedResult(err)
+		}
+		nodeID := s.graph.order[heap.Pop(pending).(int)]
+
+		value, err := s.circuit.recomputeNode(s.graph, nodeID, s.values)
+		if err != nil {
+			return failedResult(err)
+		}
+		if old, exists := s.values[nodeID]; exists && old == value {
+			continue // Nothing downstream can change
+		}
+		s.values[nodeID] = value
+		enqueueFanOut(nodeID)
+	}
+
+	return s.Result()
+}
+
+// positionHeap is a min-heap of topological positions.
+type positionHeap []int
+
+func (h positionHeap) Len() int            { return len(h) }
+func (h positionHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h positionHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *positionHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *positionHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,123 @@
+package entity
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// LogicValue is the value of a signal in four-state logic: false, true, unknown (X)
+// when it depends on inputs that were not given or on conflicting drivers, and high
+// impedance (Z) when nothing drives it. Gates follow Kleene three-valued logic and
+// read a high-impedance input as unknown.
+type LogicValue uint8
+
+const (
+	LogicFalse LogicValue = iota
+	LogicTrue
+	LogicUnknown
+	LogicHighZ
+)
+
+// LogicFromBool converts a known Boolean to a LogicValue.
+func LogicFromBool(b bool) LogicValue {
+	if b {
+		return LogicTrue
+	}
+	return LogicFalse
+}
+
+// Bool returns the Boolean value, or nil if it is not known.
+func (v LogicValue) Bool() *bool {
+	switch v {
+	case LogicFalse:
+		b := false
+		return &b
+	case LogicTrue:
+		b := true
+		return &b
+	}
+	return nil
+}
+
+func (v LogicValue) IsValid() bool {
+	switch v {
+	case LogicFalse, LogicTrue, LogicUnknown, LogicHighZ:
+		return true
+	}
+	return false
+}
+
+func (v LogicValue) String() string {
+	switch v {
+	case LogicFalse:
+		return "FALSE"
+	case LogicTrue:
+		return "TRUE"
+	case LogicUnknown:
+		return "UNKNOWN"
+	case LogicHighZ:
+		return "HIGH_Z"
+	}
+	return fmt.Sprintf("LogicValue(%d)", uint8(v))
+}
+
+func (v *LogicValue) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	for _, candidate := range []LogicValue{LogicFalse, LogicTrue, LogicUnknown, LogicHighZ} {
+		if candidate.String() == str {
+			*v = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a valid LogicValue", str)
+}
+
+func (v LogicValue) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(v.String()))
+}
+
+// MarshalText encodes the value by name, e.g. in stored JSON.
+func (v LogicValue) MarshalText() ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("%s is not a valid LogicValue", v)
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *LogicValue) UnmarshalText(text []byte) error {
+	return v.UnmarshalGQL(string(text))
+}
+
+// resolveBus resolves the value of a net with several drivers using wired-bus
+// rules: drivers in high impedance are ignored, drivers that agree share their
+// value, and conflicting or unknown drivers make the net unknown. A net with no
+// active driver floats in high impedance.
+func resolveBus(drivers []LogicValue) LogicValue {
+	result := LogicHighZ
+	for _, driver := range drivers {
+		switch {
+		case driver == LogicHighZ:
+			continue
+		case driver == LogicUnknown:
+			return LogicUnknown
+		case result == LogicHighZ:
+			result = driver
+		case result != driver:
+			return LogicUnknown
+		}
+	}
+	return result
+}
+
+// gateInput reads a value as a gate input, where high impedance is unknown.
+func gateInput(v LogicValue) LogicValue {
+	if v == LogicHighZ {
+		return LogicUnknown
+	}
+	return v
+}
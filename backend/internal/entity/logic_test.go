@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"context"
+	"testing"
+)
+
+// Short names for the tables below.
+const (
+	F = LogicFalse
+	T = LogicTrue
+	X = LogicUnknown
+	Z = LogicHighZ
+)
+
+// Kleene's strong three-valued tables, with a high-impedance input read as unknown.
+// Rows and columns are indexed by LogicValue: FALSE, TRUE, UNKNOWN, HIGH_Z.
+var (
+	kleeneAnd = [4][4]LogicValue{
+		{F, F, F, F},
+		{F, T, X, X},
+		{F, X, X, X},
+		{F, X, X, X},
+	}
+	kleeneOr = [4][4]LogicValue{
+		{F, T, X, X},
+		{T, T, T, T},
+		{X, T, X, X},
+		{X, T, X, X},
+	}
+	kleeneNot = [4]LogicValue{T, F, X, X}
+)
+
+func TestKleeneGates(t *testing.T) {
+	for a := LogicFalse; a <= LogicHighZ; a++ {
+		if got := evaluateNot(a); got != kleeneNot[a] {
+			t.Errorf("NOT %s = %s, want %s", a, got, kleeneNot[a])
+		}
+		for b := LogicFalse; b <= LogicHighZ; b++ {
+			if got := evaluateAnd([]LogicValue{a, b}); got != kleeneAnd[a][b] {
+				t.Errorf("%s AND %s = %s, want %s", a, b, got, kleeneAnd[a][b])
+			}
+			if got := evaluateOr([]LogicValue{a, b}); got != kleeneOr[a][b] {
+				t.Errorf("%s OR %s = %s, want %s", a, b, got, kleeneOr[a][b])
+			}
+		}
+	}
+}
+
+func TestKleeneGatesWithManyInputs(t *testing.T) {
+	// A gate with more inputs folds the two-input table over them.
+	for _, a := range logicValues {
+		for _, b := range logicValues {
+			for _, c := range logicValues {
+				inputs := []LogicValue{a, b, c}
+				and := evaluateAnd([]LogicValue{evaluateAnd([]LogicValue{a, b}), c})
+				if got := evaluateAnd(inputs); got != and {
+					t.Errorf("AND %v = %s, want %s", inputs, got, and)
+				}
+				or := evaluateOr([]LogicValue{evaluateOr([]LogicValue{a, b}), c})
+				if got := evaluateOr(inputs); got != or {
+					t.Errorf("OR %v = %s, want %s", inputs, got, or)
+				}
+			}
+		}
+	}
+}
+
+func TestEvaluatePartialInputs(t *testing.T) {
+	// Y = A AND B, Z = A OR B with only A given.
+	c := buildCircuit("partial", []Node{input("a", "A"), input("b", "B"), &AndNode{ID: "and"}, &OrNode{ID: "or"}, output("y", "Y"), output("z", "Z")},
+		"a>and", "b>and", "a>or", "b>or", "and>y", "or>z")
+	validated, err := c.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	for _, tt := range []struct {
+		a    bool
+		y, z LogicValue
+	}{
+		{false, F, X},
+		{true, X, T},
+	} {
+		a := tt.a
+		result, err := validated.Evaluate(context.Background(), []*InputNodeValue{{Title: "A", Value: &a}})
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if got := result.NamedOutputs["Y"]; got != tt.y {
+			t.Errorf("A = %v: Y = %s, want %s", a, got, tt.y)
+		}
+		if got := result.NamedOutputs["Z"]; got != tt.z {
+			t.Errorf("A = %v: Z = %s, want %s", a, got, tt.z)
+		}
+		for _, output := range result.Outputs {
+			if output.Value != (output.State == LogicTrue) {
+				t.Errorf("A = %v: output %s has value %v and state %s", a, output.NodeID, output.Value, output.State)
+			}
+		}
+	}
+}
@@ -46,6 +46,39 @@ func (n *NotNode) GetID() string {
 	return n.ID
 }
 
+// TriStateNode is a tri-state buffer. While its enable input is true it drives
+// its data input onto its output; while enable is false the output is left in
+// high impedance so another driver can use the same net.
+type TriStateNode struct {
+	ID string `json:"id"`
+}
+
+func (n *TriStateNode) GetID() string {
+	return n.ID
+}
+
+// DFlipFlopNode is an edge-triggered D flip-flop. Its output is the value it holds,
+// which is replaced by the value of its input on every clock step of a Simulation.
+type DFlipFlopNode struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+func (n *DFlipFlopNode) GetID() string {
+	return n.ID
+}
+
+// SRLatchNode is a level-sensitive SR latch. Its output becomes true while its set
+// input is true, false while its reset input is true, and otherwise holds its value.
+type SRLatchNode struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+func (n *SRLatchNode) GetID() string {
+	return n.ID
+}
+
 type CircuitNode struct {
 	ID      string   `json:"id"`
 	Circuit *Circuit `json:"circuit"`
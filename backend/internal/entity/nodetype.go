@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// NodeType identifies the kind of a node.
+type NodeType string
+
+const (
+	NodeTypeInput    NodeType = "INPUT"
+	NodeTypeOutput   NodeType = "OUTPUT"
+	NodeTypeAnd      NodeType = "AND"
+	NodeTypeOr       NodeType = "OR"
+	NodeTypeNot      NodeType = "NOT"
+	NodeTypeTriState NodeType = "TRISTATE"
+	NodeTypeDFF      NodeType = "DFF"
+	NodeTypeSRLatch  NodeType = "SRLATCH"
+	NodeTypeCircuit  NodeType = "CIRCUIT"
+)
+
+// NodeTypeOf returns the type of node, or an empty NodeType for an unknown node.
+func NodeTypeOf(node Node) NodeType {
+	switch node.(type) {
+	case *InputNode:
+		return NodeTypeInput
+	case *OutputNode:
+		return NodeTypeOutput
+	case *AndNode:
+		return NodeTypeAnd
+	case *OrNode:
+		return NodeTypeOr
+	case *NotNode:
+		return NodeTypeNot
+	case *TriStateNode:
+		return NodeTypeTriState
+	case *DFlipFlopNode:
+		return NodeTypeDFF
+	case *SRLatchNode:
+		return NodeTypeSRLatch
+	case *CircuitNode:
+		return NodeTypeCircuit
+	}
+	return ""
+}
+
+// NodeTitle returns the title of node, or an empty string for node types without one.
+func NodeTitle(node Node) string {
+	switch n := node.(type) {
+	case *InputNode:
+		return n.Title
+	case *OutputNode:
+		return n.Title
+	case *DFlipFlopNode:
+		return n.Title
+	case *SRLatchNode:
+		return n.Title
+	case *wireNode:
+		return n.Title
+	}
+	return ""
+}
+
+func (t NodeType) IsValid() bool {
+	switch t {
+	case NodeTypeInput, NodeTypeOutput, NodeTypeAnd, NodeTypeOr, NodeTypeNot,
+		NodeTypeTriState, NodeTypeDFF, NodeTypeSRLatch, NodeTypeCircuit:
+		return true
+	}
+	return false
+}
+
+func (t NodeType) String() string {
+	return string(t)
+}
+
+func (t *NodeType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*t = NodeType(str)
+	if !t.IsValid() {
+		return fmt.Errorf("%s is not a valid NodeType", str)
+	}
+	return nil
+}
+
+func (t NodeType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(t.String()))
+}
@@ -0,0 +1,21 @@
+package entity
+
+// OperationKind identifies an invertible edit recorded in a circuit's operation log.
+type OperationKind string
+
+const (
+	OperationAddNode OperationKind = "ADD_NODE"
+	OperationAddEdge OperationKind = "ADD_EDGE"
+)
+
+// CircuitOperation is a single edit in a circuit's operation log.
+// Exactly one of Node or Edge is set, depending on Kind. Version is the circuit
+// version after the operation was last applied or reverted.
+type CircuitOperation struct {
+	ID        int64         `json:"id"`
+	CircuitID string        `json:"circuitID"`
+	Version   int32         `json:"version"`
+	Kind      OperationKind `json:"kind"`
+	Node      Node          `json:"node"`
+	Edge      *Edge         `json:"edge"`
+}
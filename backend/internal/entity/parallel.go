@@ -0,0 +1,183 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// parallelThreshold is the node count from which evaluation is spread across goroutines.
+	parallelThreshold = 4096
+	// parallelChunk is the number of nodes of a level a worker claims at a time.
+	// Levels smaller than two chunks are evaluated inline.
+	parallelChunk = 256
+)
+
+// parallelWorkers returns how many goroutines should evaluate a circuit with the given
+// number of nodes; 1 means sequential evaluation.
+func parallelWorkers(nodes int) int {
+	if nodes < parallelThreshold {
+		return 1
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// levelGraph is an index-based copy of an evaluationGraph, grouped into logic levels.
+// Every node of a level only depends on nodes of earlier levels, so the nodes of one
+// level can be evaluated concurrently.
+type levelGraph struct {
+	nodes []Node
+	// sources holds the indices of each node's sources, in edge order.
+	sources [][]int
+	// ports holds the target port of each entry of sources.
+	ports [][]string
+	// levels holds the indices of the nodes of each level.
+	levels [][]int
+}
+
+func newLevelGraph(graph *evaluationGraph) *levelGraph {
+	index := make(map[string]int, len(graph.order))
+	lg := &levelGraph{
+		nodes:   make([]Node, len(graph.order)),
+		sources: make([][]int, len(graph.order)),
+		ports:   make([][]string, len(graph.order)),
+	}
+	depth := make([]int, len(graph.order))
+
+	// graph.order places every source before its targets, so each depth is final
+	// by the time a node's targets look it up.
+	for i, nodeID := range graph.order {
+		index[nodeID] = i
+		lg.nodes[i] = graph.nodeMap[nodeID]
+
+		sourceIDs := graph.incomingEdges[nodeID]
+		lg.ports[i] = graph.incomingPorts[nodeID]
+		lg.sources[i] = make([]int, len(sourceIDs))
+		for j, sourceID := range sourceIDs {
+			source := index[sourceID]
+			lg.sources[i][j] = source
+			if depth[source]+1 > depth[i] {
+				depth[i] = depth[source] + 1
+			}
+		}
+
+		for len(lg.levels) <= depth[i] {
+			lg.levels = append(lg.levels, nil)
+		}
+		lg.levels[depth[i]] = append(lg.levels[depth[i]], i)
+	}
+
+	return lg
+}
+
+// computeValuesParallel evaluates the circuit level by level, splitting each large
+// level across workers goroutines. Only the values of InputNodes and OutputNodes
+// are returned.
+func (v *ValidatedCircuit) computeValuesParallel(ctx context.Context, inputs []*InputNodeValue, workers int) (map[string]LogicValue, error) {
+	v.levelsOnce.Do(func() {
+		v.levels = newLevelGraph(v.graph)
+	})
+	lg := v.levels
+
+	inputValues := make(map[string]LogicValue, len(inputs))
+	for _, input := range inputs {
+		nodeID, err := v.graph.inputNodeID(input)
+		if err != nil {
+			return nil, err
+		}
+		inputValues[nodeID] = input.state()
+	}
+
+	values := make([]LogicValue, len(lg.nodes))
+	evaluate := func(nodes []int, scratch []LogicValue) ([]LogicValue, error) {
+		for _, i := range nodes {
+			node := lg.nodes[i]
+			if _, ok := node.(*InputNode); ok {
+				value, exists := inputValues[node.GetID()]
+				if !exists {
+					value = LogicUnknown
+				}
+				values[i] = value
+				continue
+			}
+			if isStateNode(node) {
+				values[i] = LogicUnknown
+				continue
+			}
+
+			scratch = scratch[:0]
+			for _, source := range lg.sources[i] {
+				scratch = append(scratch, values[source])
+			}
+			result, err := v.circuit.evaluateNode(node, scratch, lg.ports[i])
+			if err != nil {
+				return scratch, fmt.Errorf("failed to evaluate node %s: %w", node.GetID(), err)
+			}
+			values[i] = result
+		}
+		return scratch, nil
+	}
+
+	var scratch []LogicValue
+	for _, level := range lg.levels {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(level) < 2*parallelChunk {
+			var err error
+			if scratch, err = evaluate(level, scratch); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Workers claim chunks of the level until none are left; the WaitGroup is the
+		// barrier that makes this level's values visible to the next one.
+		var (
+			next     atomic.Int64
+			wg       sync.WaitGroup
+			errOnce  sync.Once
+			firstErr error
+			failed   atomic.Bool
+		)
+		chunks := (len(level) + parallelChunk - 1) / parallelChunk
+		for w := 0; w < min(workers, chunks); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var scratch []LogicValue
+				for !failed.Load() {
+					chunk := int(next.Add(1)) - 1
+					if chunk >= chunks {
+						return
+					}
+					end := min((chunk+1)*parallelChunk, len(level))
+
+					var err error
+					if scratch, err = evaluate(level[chunk*parallelChunk:end], scratch); err != nil {
+						errOnce.Do(func() { firstErr = err })
+						failed.Store(true)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if firstErr != nil {
+			return nil, firstErr
+		}
+	}
+
+	computedValues := make(map[string]LogicValue, len(inputValues))
+	for i, node := range lg.nodes {
+		switch node.(type) {
+		case *InputNode, *OutputNode:
+			computedValues[node.GetID()] = values[i]
+		}
+	}
+	return computedValues, nil
+}
@@ -0,0 +1,166 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// randomCircuit builds a layered random circuit of AND, OR and NOT gates.
+func randomCircuit(r *rand.Rand, inputs, gates, outputs int) *Circuit {
+	c := &Circuit{ID: "random"}
+	var ids []string
+	edgeID := 0
+	addEdge := func(source, target string) {
+		c.Edges = append(c.Edges, &Edge{ID: fmt.Sprint(edgeID), SourceNodeID: source, TargetNodeID: target})
+		edgeID++
+	}
+
+	for i := 0; i < inputs; i++ {
+		id := fmt.Sprintf("in%d", i)
+		c.Nodes = append(c.Nodes, &InputNode{ID: id})
+		ids = append(ids, id)
+	}
+
+	for g := 0; g < gates; g++ {
+		id := fmt.Sprintf("g%d", g)
+		fanIn := 1 + r.Intn(3)
+		switch r.Intn(3) {
+		case 0:
+			c.Nodes = append(c.Nodes, &AndNode{ID: id})
+		case 1:
+			c.Nodes = append(c.Nodes, &OrNode{ID: id})
+		default:
+			c.Nodes = append(c.Nodes, &NotNode{ID: id})
+			fanIn = 1
+		}
+
+		// Prefer recent nodes so the circuit gets deep as well as wide.
+		window := min(len(ids), 64+r.Intn(len(ids)))
+		for j := 0; j < fanIn; j++ {
+			addEdge(ids[len(ids)-1-r.Intn(window)], id)
+		}
+		ids = append(ids, id)
+	}
+
+	for o := 0; o < outputs; o++ {
+		id := fmt.Sprintf("out%d", o)
+		c.Nodes = append(c.Nodes, &OutputNode{ID: id})
+		addEdge(ids[len(ids)-1-o], id)
+	}
+
+	return c
+}
+
+func randomInputs(r *rand.Rand, count int) []*InputNodeValue {
+	inputs := make([]*InputNodeValue, count)
+	for i := range inputs {
+		inputs[i] = &InputNodeValue{NodeID: fmt.Sprintf("in%d", i)}
+		// Leave some inputs unknown
+		if r.Intn(8) != 0 {
+			value := r.Intn(2) == 1
+			inputs[i].Value = &value
+		}
+	}
+	return inputs
+}
+
+func TestParallelEvaluationMatchesSequential(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+
+	for iteration := 0; iteration < 5; iteration++ {
+		c := randomCircuit(r, 64, 20000, 32)
+		validated, err := c.Validate()
+		if err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+
+		for run := 0; run < 4; run++ {
+			inputs := randomInputs(r, 64)
+
+			sequential, err := c.computeValues(ctx, validated.graph, inputs, nil)
+			if err != nil {
+				t.Fatalf("computeValues() error = %v", err)
+			}
+			parallel, err := validated.computeValuesParallel(ctx, inputs, 4)
+			if err != nil {
+				t.Fatalf("computeValuesParallel() error = %v", err)
+			}
+
+			for o := 0; o < 32; o++ {
+				id := fmt.Sprintf("out%d", o)
+				if parallel[id] != sequential[id] {
+					t.Fatalf("output %s: parallel = %v, sequential = %v", id, parallel[id], sequential[id])
+				}
+			}
+		}
+	}
+}
+
+func TestParallelEvaluationReportsErrors(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	c := randomCircuit(r, 64, 20000, 32)
+	validated, err := c.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if _, err := validated.computeValuesParallel(context.Background(), []*InputNodeValue{{NodeID: "g0"}}, 4); err == nil {
+		t.Fatal("expected an error for a value given to a gate")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := validated.computeValuesParallel(ctx, randomInputs(r, 64), 4); err != context.Canceled {
+		t.Fatalf("error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestTopologicalSortAllowsDuplicateEdges(t *testing.T) {
+	c := &Circuit{
+		Nodes: []Node{&InputNode{ID: "a"}, &AndNode{ID: "and"}, &OutputNode{ID: "out"}},
+		Edges: []*Edge{
+			{ID: "1", SourceNodeID: "a", TargetNodeID: "and"},
+			{ID: "2", SourceNodeID: "a", TargetNodeID: "and"},
+			{ID: "3", SourceNodeID: "and", TargetNodeID: "out"},
+		},
+	}
+
+	value := true
+	result, err := c.EvaluateCircuit(context.Background(), []*InputNodeValue{{NodeID: "a", Value: &value}})
+	if err != nil {
+		t.Fatalf("EvaluateCircuit() error = %v", err)
+	}
+	if result.Outputs[0].State != LogicTrue {
+		t.Fatalf("output = %v, want %v", result.Outputs[0].State, LogicTrue)
+	}
+}
+
+func benchmarkEvaluation(b *testing.B, parallel bool) {
+	r := rand.New(rand.NewSource(3))
+	c := randomCircuit(r, 256, 200000, 64)
+	validated, err := c.Validate()
+	if err != nil {
+		b.Fatalf("Validate() error = %v", err)
+	}
+	inputs := randomInputs(r, 256)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if parallel {
+			_, err = validated.computeValuesParallel(ctx, inputs, parallelWorkers(len(c.Nodes)))
+		} else {
+			_, err = c.computeValues(ctx, validated.graph, inputs, nil)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateSequential(b *testing.B) { benchmarkEvaluation(b, false) }
+
+func BenchmarkEvaluateParallel(b *testing.B) { benchmarkEvaluation(b, true) }
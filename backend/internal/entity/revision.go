@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// CircuitRevision describes one immutable, numbered snapshot of a circuit.
+// A new revision is recorded every time the circuit changes.
+type CircuitRevision struct {
+	CircuitID string    `json:"circuitID"`
+	Revision  int32     `json:"revision"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,292 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+)
+
+// isStateNode reports whether node holds state between clock steps. A state node's
+// output is the value it holds, so edges into it do not take part in combinational
+// evaluation, and feedback loops through it are not cycles.
+func isStateNode(node Node) bool {
+	switch node.(type) {
+	case *DFlipFlopNode, *SRLatchNode:
+		return true
+	}
+	return false
+}
+
+// SimulationStep is the state of a Simulation after a step.
+type SimulationStep struct {
+	// SimulationID is set by the service that owns the Simulation.
+	SimulationID string        `json:"simulationID"`
+	Cycle        int32         `json:"cycle"`
+	Outputs      []*NodeOutput `json:"outputs"`
+	NamedOutputs NamedOutputs  `json:"namedOutputs"`
+	// State holds the value of every flip-flop and latch, ordered by title and then ID.
+	State []*NodeOutput `json:"state"`
+}
+
+// Simulation steps a sequential circuit through clock cycles, keeping the values
+// of its inputs and the state of its flip-flops and latches between steps.
+//
+// Every DFlipFlopNode is driven by the same clock: a clock step samples the input of
+// each flip-flop and then updates all of them at once. SR latches are level-sensitive
+// and settle whenever inputs or flip-flops change.
+//
+// A Simulation is bound to the circuit structure it was created from. It is not
+// safe for concurrent use.
+type Simulation struct {
+	circuit *Circuit
+	graph   *evaluationGraph
+	inputs  map[string]LogicValue
+	state   map[string]LogicValue
+	values  map[string]LogicValue
+	cycle   int32
+}
+
+// NewSimulation validates the circuit and starts a simulation with the given inputs.
+// Inputs that are not given are unknown, and every flip-flop and latch starts out false.
+func (c *Circuit) NewSimulation(ctx context.Context, inputs []*InputNodeValue) (*Simulation, error) {
+	validated, err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Simulation{
+		circuit: c,
+		graph:   validated.graph,
+		inputs:  make(map[string]LogicValue),
+	}
+	if err := s.applyInputs(inputs); err != nil {
+		return nil, err
+	}
+	s.resetState()
+
+	if err := s.settle(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the simulation's outputs and state without stepping it.
+func (s *Simulation) Current() (*SimulationStep, error) {
+	outputs, err := s.circuit.collectOutputs(s.values)
+	if err != nil {
+		return nil, err
+	}
+
+	result := successfulResult(outputs)
+	return &SimulationStep{
+		Cycle:        s.cycle,
+		Outputs:      result.Outputs,
+		NamedOutputs: result.NamedOutputs,
+		State:        s.collectState(),
+	}, nil
+}
+
+// SetInputs updates the given inputs, keeping the previous value of any input not
+// mentioned, and settles the circuit without a clock step.
+func (s *Simulation) SetInputs(ctx context.Context, inputs []*InputNodeValue) (*SimulationStep, error) {
+	next := s.clone()
+	if err := next.applyInputs(inputs); err != nil {
+		return nil, err
+	}
+	if err := next.settle(ctx); err != nil {
+		return nil, err
+	}
+	step, err := next.Current()
+	if err != nil {
+		return nil, err
+	}
+	*s = *next
+	return step, nil
+}
+
+// StepClock advances the clock cycles times and returns the outputs and state
+// after each step. If a step fails, the simulation is left as it was.
+func (s *Simulation) StepClock(ctx context.Context, cycles int) ([]*SimulationStep, error) {
+	if cycles < 1 {
+		return nil, fmt.Errorf("cycles must be at least 1, got %d", cycles)
+	}
+
+	next := s.clone()
+	steps := make([]*SimulationStep, 0, cycles)
+	for i := 0; i < cycles; i++ {
+		// Sample every flip-flop before updating any, as a shared clock edge would.
+		sampled := make(map[string]LogicValue)
+		for _, node := range next.circuit.Nodes {
+			if _, ok := node.(*DFlipFlopNode); ok {
+				sampled[node.GetID()] = gateInput(resolveBus(next.stateInputs(node.GetID(), "")))
+			}
+		}
+		for nodeID, value := range sampled {
+			next.state[nodeID] = value
+		}
+		next.cycle++
+
+		if err := next.settle(ctx); err != nil {
+			return nil, err
+		}
+		step, err := next.Current()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	*s = *next
+	return steps, nil
+}
+
+// Reset clears every flip-flop and latch to false and the cycle count to zero.
+// Inputs keep their values.
+func (s *Simulation) Reset(ctx context.Context) (*SimulationStep, error) {
+	next := s.clone()
+	next.resetState()
+	next.cycle = 0
+	if err := next.settle(ctx); err != nil {
+		return nil, err
+	}
+	step, err := next.Current()
+	if err != nil {
+		return nil, err
+	}
+	*s = *next
+	return step, nil
+}
+
+// clone copies the simulation, so that a change can be made to the copy and only
+// kept if it succeeds. The values map is shared, as settle replaces it rather than
+// modifying it.
+func (s *Simulation) clone() *Simulation {
+	c := *s
+	c.inputs = make(map[string]LogicValue, len(s.inputs))
+	for nodeID, value := range s.inputs {
+		c.inputs[nodeID] = value
+	}
+	c.state = make(map[string]LogicValue, len(s.state))
+	for nodeID, value := range s.state {
+		c.state[nodeID] = value
+	}
+	return &c
+}
+
+func (s *Simulation) applyInputs(inputs []*InputNodeValue) error {
+	// Resolve everything up front so a bad input leaves the simulation untouched.
+	nodeIDs := make([]string, len(inputs))
+	for i, input := range inputs {
+		nodeID, err := s.graph.inputNodeID(input)
+		if err != nil {
+			return err
+		}
+		nodeIDs[i] = nodeID
+	}
+	for i, input := range inputs {
+		s.inputs[nodeIDs[i]] = input.state()
+	}
+	return nil
+}
+
+func (s *Simulation) resetState() {
+	s.state = make(map[string]LogicValue)
+	for _, node := range s.circuit.Nodes {
+		if isStateNode(node) {
+			s.state[node.GetID()] = LogicFalse
+		}
+	}
+}
+
+// settle evaluates the combinational logic and updates the latches until no latch
+// changes. Latches that still change after every latch had a chance to settle are
+// oscillating and become unknown.
+func (s *Simulation) settle(ctx context.Context) error {
+	inputs := make([]*InputNodeValue, 0, len(s.inputs))
+	for nodeID, value := range s.inputs {
+		inputs = append(inputs, &InputNodeValue{NodeID: nodeID, Value: value.Bool()})
+	}
+
+	var latches []string
+	for _, node := range s.circuit.Nodes {
+		if _, ok := node.(*SRLatchNode); ok {
+			latches = append(latches, node.GetID())
+		}
+	}
+
+	for iteration := 0; ; iteration++ {
+		values, err := s.circuit.computeValues(ctx, s.graph, inputs, s.state)
+		if err != nil {
+			return err
+		}
+		s.values = values
+
+		var changed []string
+		for _, nodeID := range latches {
+			set := gateInput(resolveBus(s.stateInputs(nodeID, PortSet)))
+			reset := gateInput(resolveBus(s.stateInputs(nodeID, PortReset)))
+			if next := nextLatchValue(s.state[nodeID], set, reset); next != s.state[nodeID] {
+				s.state[nodeID] = next
+				changed = append(changed, nodeID)
+			}
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+
+		if iteration >= len(latches) {
+			for _, nodeID := range changed {
+				s.state[nodeID] = LogicUnknown
+			}
+			// Unknown latches cannot change any further, so one more pass settles.
+			values, err := s.circuit.computeValues(ctx, s.graph, inputs, s.state)
+			if err != nil {
+				return err
+			}
+			s.values = values
+			return nil
+		}
+	}
+}
+
+// stateInputs returns the current values driving the given port of a state node.
+func (s *Simulation) stateInputs(nodeID, port string) []LogicValue {
+	var values []LogicValue
+	for i, sourceID := range s.graph.stateEdges[nodeID] {
+		if s.graph.statePorts[nodeID][i] == port {
+			values = append(values, s.values[sourceID])
+		}
+	}
+	return values
+}
+
+// nextLatchValue applies an SR latch's set and reset inputs to its current value.
+// Setting and resetting at once is invalid and makes the latch unknown.
+func nextLatchValue(current, set, reset LogicValue) LogicValue {
+	switch {
+	case set == LogicFalse && reset == LogicFalse:
+		return current
+	case set == LogicTrue && reset == LogicFalse:
+		return LogicTrue
+	case set == LogicFalse && reset == LogicTrue:
+		return LogicFalse
+	case set == LogicFalse && reset == LogicUnknown && current == LogicFalse,
+		set == LogicUnknown && reset == LogicFalse && current == LogicTrue:
+		// The only possible change would not change the value.
+		return current
+	}
+	return LogicUnknown
+}
+
+// collectState reads the value of every flip-flop and latch, ordered by title and then ID.
+func (s *Simulation) collectState() []*NodeOutput {
+	state := []*NodeOutput{}
+	for _, node := range s.circuit.Nodes {
+		if !isStateNode(node) {
+			continue
+		}
+		value := s.state[node.GetID()]
+		state = append(state, &NodeOutput{NodeID: node.GetID(), Title: NodeTitle(node), Value: value == LogicTrue, State: value})
+	}
+
+	sortNodeOutputs(state)
+	return state
+}
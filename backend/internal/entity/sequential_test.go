@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"context"
+	"testing"
+)
+
+// countingContext counts calls to Err and reports cancellation once limit is reached.
+type countingContext struct {
+	context.Context
+	calls, limit int
+}
+
+func (c *countingContext) Err() error {
+	c.calls++
+	if c.limit > 0 && c.calls > c.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestStepClockFailureLeavesSimulationUnchanged(t *testing.T) {
+	// A flip-flop fed by its own inverse toggles on every clock step.
+	c := buildCircuit("toggle", []Node{&DFlipFlopNode{ID: "ff", Title: "Q"}, &NotNode{ID: "n"}, output("q", "Q")},
+		"ff>n", "n>ff", "ff>q")
+	sim, err := c.NewSimulation(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("NewSimulation() error = %v", err)
+	}
+
+	counter := &countingContext{Context: context.Background()}
+	if _, err := sim.StepClock(counter, 1); err != nil {
+		t.Fatalf("StepClock() error = %v", err)
+	}
+	perStep := counter.calls
+
+	// Fail partway through the second of three steps.
+	failing := &countingContext{Context: context.Background(), limit: perStep + perStep/2}
+	if _, err := sim.StepClock(failing, 3); err == nil {
+		t.Fatal("StepClock() succeeded with a cancelled context")
+	}
+	step, err := sim.Current()
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if step.Cycle != 1 || step.NamedOutputs["Q"] != LogicTrue {
+		t.Errorf("after a failed StepClock: cycle = %d, Q = %s, want cycle 1 and Q TRUE", step.Cycle, step.NamedOutputs["Q"])
+	}
+
+	steps, err := sim.StepClock(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("StepClock() error = %v", err)
+	}
+	if steps[0].Cycle != 2 || steps[0].NamedOutputs["Q"] != LogicFalse {
+		t.Errorf("next step: cycle = %d, Q = %s, want cycle 2 and Q FALSE", steps[0].Cycle, steps[0].NamedOutputs["Q"])
+	}
+}
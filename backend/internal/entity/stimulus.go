@@ -0,0 +1,517 @@
+package entity
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxStimulusRows caps the rows of a stimulus file.
+const maxStimulusRows = 100000
+
+// StimulusFormat is the file format of a stimulus.
+type StimulusFormat string
+
+const (
+	// StimulusCSV is a table with a header row naming the columns.
+	StimulusCSV StimulusFormat = "CSV"
+	// StimulusJSON is an array of objects keyed by column name.
+	StimulusJSON StimulusFormat = "JSON"
+)
+
+func (f StimulusFormat) IsValid() bool {
+	switch f {
+	case StimulusCSV, StimulusJSON:
+		return true
+	}
+	return false
+}
+
+func (f StimulusFormat) String() string {
+	return string(f)
+}
+
+func (f *StimulusFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*f = StimulusFormat(str)
+	if !f.IsValid() {
+		return fmt.Errorf("%s is not a valid StimulusFormat", str)
+	}
+	return nil
+}
+
+func (f StimulusFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(f.String()))
+}
+
+// StimulusMode selects how a stimulus drives a circuit.
+type StimulusMode string
+
+const (
+	// StimulusSteps evaluates the circuit after each row.
+	StimulusSteps StimulusMode = "STEPS"
+	// StimulusClocked applies each row and then steps the clock of a sequential circuit.
+	StimulusClocked StimulusMode = "CLOCKED"
+	// StimulusTimed applies each row at the time in its time column in an event-driven
+	// simulation with default delays, and samples outputs just before the next row.
+	StimulusTimed StimulusMode = "TIMED"
+)
+
+func (m StimulusMode) IsValid() bool {
+	switch m {
+	case StimulusSteps, StimulusClocked, StimulusTimed:
+		return true
+	}
+	return false
+}
+
+func (m StimulusMode) String() string {
+	return string(m)
+}
+
+func (m *StimulusMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*m = StimulusMode(str)
+	if !m.IsValid() {
+		return fmt.Errorf("%s is not a valid StimulusMode", str)
+	}
+	return nil
+}
+
+func (m StimulusMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(m.String()))
+}
+
+// Stimulus is a parsed stimulus file: rows of cells keyed by column name.
+//
+// Columns name an input or output by title, or the time of a row ("time"). A title
+// used by both an input and an output must be prefixed with "in:" or "out:". Input
+// cells hold 0, 1 or x (unknown); an empty cell keeps the previous value. Output
+// cells hold the expected value: 0, 1, x or z, or empty or "-" for don't care.
+type Stimulus struct {
+	Columns []string
+	Rows    []map[string]string
+}
+
+// ParseStimulus parses a stimulus file.
+func ParseStimulus(format StimulusFormat, content string) (*Stimulus, error) {
+	switch format {
+	case StimulusCSV:
+		return parseStimulusCSV(content)
+	case StimulusJSON:
+		return parseStimulusJSON(content)
+	}
+	return nil, fmt.Errorf("unsupported stimulus format: %s", format)
+}
+
+// DetectStimulusFormat guesses the format of a stimulus file from its content.
+func DetectStimulusFormat(content string) StimulusFormat {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		return StimulusJSON
+	}
+	return StimulusCSV
+}
+
+func parseStimulusCSV(content string) (*Stimulus, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("stimulus is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stimulus header: %w", err)
+	}
+	stimulus := &Stimulus{}
+	seen := make(map[string]bool, len(header))
+	for _, column := range header {
+		column = strings.TrimSpace(column)
+		if seen[column] {
+			return nil, fmt.Errorf("stimulus header has more than one column named '%s'", column)
+		}
+		seen[column] = true
+		stimulus.Columns = append(stimulus.Columns, column)
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stimulus: %w", err)
+		}
+		if len(stimulus.Rows) >= maxStimulusRows {
+			return nil, fmt.Errorf("stimulus has more than %d rows", maxStimulusRows)
+		}
+		row := make(map[string]string, len(record))
+		for i, cell := range record {
+			row[stimulus.Columns[i]] = strings.TrimSpace(cell)
+		}
+		stimulus.Rows = append(stimulus.Rows, row)
+	}
+	return stimulus, nil
+}
+
+func parseStimulusJSON(content string) (*Stimulus, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	var rows []map[string]json.RawMessage
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse stimulus: %w", err)
+	}
+	if len(rows) > maxStimulusRows {
+		return nil, fmt.Errorf("stimulus has more than %d rows", maxStimulusRows)
+	}
+
+	stimulus := &Stimulus{}
+	seen := make(map[string]bool)
+	for i, raw := range rows {
+		row := make(map[string]string, len(raw))
+		for column, value := range raw {
+			cell, err := jsonStimulusCell(value)
+			if err != nil {
+				return nil, fmt.Errorf("row %d, column '%s': %w", i+1, column, err)
+			}
+			row[column] = cell
+			if !seen[column] {
+				seen[column] = true
+				stimulus.Columns = append(stimulus.Columns, column)
+			}
+		}
+		stimulus.Rows = append(stimulus.Rows, row)
+	}
+	// Object keys are unordered, so order the columns for a stable result.
+	sort.Strings(stimulus.Columns)
+	return stimulus, nil
+}
+
+// jsonStimulusCell converts a JSON value to the text of a CSV cell: booleans and
+// 0 or 1 become "0" or "1", null becomes "x", and strings are kept.
+func jsonStimulusCell(value json.RawMessage) (string, error) {
+	trimmed := bytes.TrimSpace(value)
+	switch string(trimmed) {
+	case "true":
+		return "1", nil
+	case "false":
+		return "0", nil
+	case "null":
+		return "x", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(trimmed, &text); err == nil {
+		return strings.TrimSpace(text), nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(trimmed, &number); err == nil {
+		return number.String(), nil
+	}
+	return "", fmt.Errorf("unsupported value %s", trimmed)
+}
+
+// OutputMismatch is an output whose value differs from the expected one.
+type OutputMismatch struct {
+	NodeID   string     `json:"nodeID"`
+	Title    string     `json:"title"`
+	Expected LogicValue `json:"expected"`
+	Actual   LogicValue `json:"actual"`
+}
+
+// DiffOutputs compares outputs against expected values keyed by output title. Outputs
+// without an expected value are don't-cares.
+func DiffOutputs(outputs []*NodeOutput, expected map[string]LogicValue) []*OutputMismatch {
+	mismatches := []*OutputMismatch{}
+	for _, output := range outputs {
+		want, ok := expected[output.Title]
+		if !ok || output.Title == "" || want == output.State {
+			continue
+		}
+		mismatches = append(mismatches, &OutputMismatch{
+			NodeID:   output.NodeID,
+			Title:    output.Title,
+			Expected: want,
+			Actual:   output.State,
+		})
+	}
+	return mismatches
+}
+
+// StimulusStepResult holds the outputs after one row of a stimulus.
+type StimulusStepResult struct {
+	// Step is the 1-based row number.
+	Step int32 `json:"step"`
+	// Time is the row's time in TIMED mode, and otherwise its index from 0.
+	Time         int32         `json:"time"`
+	Outputs      []*NodeOutput `json:"outputs"`
+	NamedOutputs NamedOutputs  `json:"namedOutputs"`
+	// Mismatches lists the outputs that differ from the row's expected values.
+	Mismatches []*OutputMismatch `json:"mismatches"`
+	Passed     bool              `json:"passed"`
+}
+
+// StimulusResult is the outcome of RunStimulus.
+type StimulusResult struct {
+	Steps []*StimulusStepResult `json:"steps"`
+	// Checked is set when the stimulus has expected-output columns.
+	Checked     bool  `json:"checked"`
+	Passed      bool  `json:"passed"`
+	FailedSteps int32 `json:"failedSteps"`
+}
+
+// stimulusRow is a row of a stimulus resolved against a circuit.
+type stimulusRow struct {
+	time     int32
+	inputs   []*InputNodeValue
+	expected map[string]LogicValue
+}
+
+// RunStimulus drives the circuit with the rows of a stimulus and compares the outputs
+// after each row with its expected values. Inputs keep their value until a row
+// changes them, and start out unknown.
+func (c *Circuit) RunStimulus(ctx context.Context, stimulus *Stimulus, mode StimulusMode) (*StimulusResult, error) {
+	rows, checked, err := c.resolveStimulus(stimulus, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs [][]*NodeOutput
+	switch mode {
+	case StimulusSteps:
+		outputs, err = c.runStimulusSteps(ctx, rows)
+	case StimulusClocked:
+		outputs, err = c.runStimulusClocked(ctx, rows)
+	case StimulusTimed:
+		outputs, err = c.runStimulusTimed(ctx, rows)
+	default:
+		return nil, fmt.Errorf("unsupported stimulus mode: %s", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StimulusResult{Steps: make([]*StimulusStepResult, len(rows)), Checked: checked, Passed: true}
+	for i, row := range rows {
+		evaluation := successfulResult(outputs[i])
+		step := &StimulusStepResult{
+			Step:         int32(i + 1),
+			Time:         row.time,
+			Outputs:      evaluation.Outputs,
+			NamedOutputs: evaluation.NamedOutputs,
+			Mismatches:   DiffOutputs(evaluation.Outputs, row.expected),
+		}
+		step.Passed = len(step.Mismatches) == 0
+		if !step.Passed {
+			result.Passed = false
+			result.FailedSteps++
+		}
+		result.Steps[i] = step
+	}
+	return result, nil
+}
+
+// resolveStimulus matches the columns of a stimulus to the circuit's inputs and outputs
+// and parses its cells. It reports whether any column holds expected outputs.
+func (c *Circuit) resolveStimulus(stimulus *Stimulus, mode StimulusMode) ([]*stimulusRow, bool, error) {
+	if stimulus == nil {
+		return nil, false, fmt.Errorf("stimulus cannot be nil")
+	}
+	inputs := c.InputsByTitle()
+	outputs := make(map[string]int)
+	for _, node := range c.Nodes {
+		if n, ok := node.(*OutputNode); ok && n.Title != "" {
+			outputs[n.Title]++
+		}
+	}
+
+	inputColumns := make(map[string]string)  // column -> input node ID
+	outputColumns := make(map[string]string) // column -> output title
+	inputNodes := make(map[string]string)    // input node ID -> column
+	outputTitles := make(map[string]string)  // output title -> column
+	timeColumn := ""
+	for _, column := range stimulus.Columns {
+		title := column
+		kind := ""
+		if prefix, rest, ok := strings.Cut(column, ":"); ok && (prefix == "in" || prefix == "out") {
+			kind, title = prefix, rest
+		}
+		_, isInput := inputs[title]
+		isOutput := outputs[title] > 0
+
+		switch {
+		case kind == "" && strings.EqualFold(title, "time") && !isInput && !isOutput:
+			timeColumn = column
+		case kind == "" && isInput && isOutput:
+			return nil, false, fmt.Errorf("column '%s' matches both an input and an output; prefix it with 'in:' or 'out:'", column)
+		case kind != "out" && isInput && inputs[title] == "":
+			return nil, false, fmt.Errorf("column '%s' matches several InputNodes titled '%s'", column, title)
+		case kind != "out" && isInput:
+			if other, exists := inputNodes[inputs[title]]; exists {
+				return nil, false, fmt.Errorf("columns '%s' and '%s' both set input '%s'", other, column, title)
+			}
+			inputNodes[inputs[title]] = column
+			inputColumns[column] = inputs[title]
+		case kind != "in" && outputs[title] > 1:
+			return nil, false, fmt.Errorf("column '%s' matches several OutputNodes titled '%s'", column, title)
+		case kind != "in" && isOutput:
+			if other, exists := outputTitles[title]; exists {
+				return nil, false, fmt.Errorf("columns '%s' and '%s' both expect output '%s'", other, column, title)
+			}
+			outputTitles[title] = column
+			outputColumns[column] = title
+		case column == "":
+			return nil, false, fmt.Errorf("stimulus has a column without a name")
+		default:
+			return nil, false, fmt.Errorf("column '%s' matches no titled input or output", column)
+		}
+	}
+	if mode == StimulusTimed && timeColumn == "" {
+		return nil, false, fmt.Errorf("TIMED stimulus needs a time column")
+	}
+
+	rows := make([]*stimulusRow, len(stimulus.Rows))
+	for i, cells := range stimulus.Rows {
+		row := &stimulusRow{time: int32(i), expected: make(map[string]LogicValue)}
+		for _, column := range stimulus.Columns {
+			cell := cells[column]
+			if column == timeColumn {
+				if mode != StimulusTimed {
+					continue
+				}
+				t, err := strconv.ParseInt(cell, 10, 32)
+				if err != nil || t < 0 {
+					return nil, false, fmt.Errorf("row %d: invalid time '%s'", i+1, cell)
+				}
+				if i > 0 && int32(t) < rows[i-1].time {
+					return nil, false, fmt.Errorf("row %d: time %d is earlier than the previous row", i+1, t)
+				}
+				row.time = int32(t)
+			} else if nodeID, ok := inputColumns[column]; ok {
+				if cell == "" {
+					continue
+				}
+				value, err := parseStimulusValue(cell)
+				if err != nil || value == LogicHighZ {
+					return nil, false, fmt.Errorf("row %d, column '%s': invalid input value '%s'", i+1, column, cell)
+				}
+				row.inputs = append(row.inputs, &InputNodeValue{NodeID: nodeID, Value: value.Bool()})
+			} else if title, ok := outputColumns[column]; ok {
+				if cell == "" || cell == "-" {
+					continue
+				}
+				value, err := parseStimulusValue(cell)
+				if err != nil {
+					return nil, false, fmt.Errorf("row %d, column '%s': invalid expected value '%s'", i+1, column, cell)
+				}
+				row.expected[title] = value
+			}
+		}
+		rows[i] = row
+	}
+	return rows, len(outputColumns) > 0, nil
+}
+
+// parseStimulusValue parses a cell holding 0, 1, x or z (or true or false).
+func parseStimulusValue(cell string) (LogicValue, error) {
+	switch strings.ToLower(cell) {
+	case "0", "false", "f", "l":
+		return LogicFalse, nil
+	case "1", "true", "t", "h":
+		return LogicTrue, nil
+	case "x":
+		return LogicUnknown, nil
+	case "z":
+		return LogicHighZ, nil
+	}
+	return LogicUnknown, fmt.Errorf("invalid logic value '%s'", cell)
+}
+
+func (c *Circuit) runStimulusSteps(ctx context.Context, rows []*stimulusRow) ([][]*NodeOutput, error) {
+	session, err := c.NewEvaluationSession(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([][]*NodeOutput, len(rows))
+	for i, row := range rows {
+		result, err := session.SetInputs(ctx, row.inputs)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		outputs[i] = result.Outputs
+	}
+	return outputs, nil
+}
+
+func (c *Circuit) runStimulusClocked(ctx context.Context, rows []*stimulusRow) ([][]*NodeOutput, error) {
+	simulation, err := c.NewSimulation(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([][]*NodeOutput, len(rows))
+	for i, row := range rows {
+		if _, err := simulation.SetInputs(ctx, row.inputs); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		steps, err := simulation.StepClock(ctx, 1)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		outputs[i] = steps[0].Outputs
+	}
+	return outputs, nil
+}
+
+func (c *Circuit) runStimulusTimed(ctx context.Context, rows []*stimulusRow) ([][]*NodeOutput, error) {
+	var events []*TimedInputValue
+	for _, row := range rows {
+		for _, input := range row.inputs {
+			events = append(events, &TimedInputValue{Time: row.time, NodeID: input.NodeID, Value: input.Value})
+		}
+	}
+	timing, err := c.SimulateTiming(ctx, events, TimingOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay the output changes, sampling just before the next row's time.
+	current := make(map[string]*NodeOutput)
+	var order []*NodeOutput
+	for _, output := range timing.Outputs {
+		sample := &NodeOutput{NodeID: output.NodeID, Title: output.Title, State: LogicUnknown}
+		current[output.NodeID] = sample
+		order = append(order, sample)
+	}
+	outputs := make([][]*NodeOutput, len(rows))
+	next := 0
+	for i := range rows {
+		for next < len(timing.Changes) && (i == len(rows)-1 || timing.Changes[next].Time < rows[i+1].time) {
+			change := timing.Changes[next]
+			if sample, ok := current[change.NodeID]; ok {
+				sample.State = change.State
+			}
+			next++
+		}
+		outputs[i] = make([]*NodeOutput, len(order))
+		for j, sample := range order {
+			outputs[i][j] = &NodeOutput{NodeID: sample.NodeID, Title: sample.Title, Value: sample.State == LogicTrue, State: sample.State}
+		}
+	}
+	return outputs, nil
+}
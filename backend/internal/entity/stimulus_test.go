@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseStimulusCSVRejectsDuplicateColumns(t *testing.T) {
+	if _, err := ParseStimulus(StimulusCSV, "A,B,A\n0,1,1\n"); err == nil || !strings.Contains(err.Error(), "'A'") {
+		t.Errorf("ParseStimulus() error = %v, want one naming column A", err)
+	}
+	// Surrounding spaces are trimmed before comparing.
+	if _, err := ParseStimulus(StimulusCSV, "A, B ,B\n0,1,1\n"); err == nil {
+		t.Error("ParseStimulus() accepted columns that differ only in spaces")
+	}
+
+	// Differently named columns may still address the same input.
+	stimulus, err := ParseStimulus(StimulusCSV, "A, in:A\n0,1\n")
+	if err != nil {
+		t.Fatalf("ParseStimulus() error = %v", err)
+	}
+	c := buildCircuit("wire", []Node{input("a", "A"), output("y", "Y")}, "a>y")
+	if _, err := c.RunStimulus(context.Background(), stimulus, StimulusSteps); err == nil || !strings.Contains(err.Error(), "both set input 'A'") {
+		t.Errorf("RunStimulus() error = %v, want one about input A", err)
+	}
+}
@@ -0,0 +1,322 @@
+package entity
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultGateDelay is the propagation delay of gates, tri-state buffers and
+	// latches unless configured otherwise. Inputs, outputs and circuit nodes have no delay.
+	DefaultGateDelay = 1
+	// maxTimingChanges caps the value changes a timing simulation records, so that
+	// oscillating circuits terminate.
+	maxTimingChanges = 100000
+)
+
+// TimedInputValue changes the value of an InputNode, identified by ID or title, at Time.
+type TimedInputValue struct {
+	Time   int32  `json:"time"`
+	NodeID string `json:"nodeID"`
+	Title  string `json:"title"`
+	Value  *bool  `json:"value"`
+}
+
+// NodeTypeDelay sets the propagation delay of every node of a type.
+type NodeTypeDelay struct {
+	Type  NodeType `json:"type"`
+	Delay int32    `json:"delay"`
+}
+
+// NodeDelay sets the propagation delay of a single node, overriding its type's delay.
+type NodeDelay struct {
+	NodeID string `json:"nodeID"`
+	Delay  int32  `json:"delay"`
+}
+
+// TimingOptions configures SimulateTiming.
+type TimingOptions struct {
+	TypeDelays []*NodeTypeDelay
+	NodeDelays []*NodeDelay
+	// Until stops the simulation after the given time; nil runs until no events remain.
+	Until *int32
+}
+
+// ValueChange records that the output of a node changed at Time.
+type ValueChange struct {
+	Time   int32      `json:"time"`
+	NodeID string     `json:"nodeID"`
+	Title  string     `json:"title"`
+	Value  *bool      `json:"value"`
+	State  LogicValue `json:"state"`
+}
+
+// TimingResult is the outcome of SimulateTiming.
+type TimingResult struct {
+	// Changes lists every value change on every node output in time order.
+	Changes []*ValueChange `json:"changes"`
+	// Outputs are the values at EndTime, ordered by title and then ID.
+	Outputs      []*NodeOutput `json:"outputs"`
+	NamedOutputs NamedOutputs  `json:"namedOutputs"`
+	// EndTime is the time of the last processed event.
+	EndTime int32 `json:"endTime"`
+	// Truncated is set when events were still pending when the simulation stopped.
+	Truncated bool `json:"truncated"`
+}
+
+// SimulateTiming runs an event-driven simulation in which every node takes its
+// propagation delay to react to a change of its inputs (transport delay), so
+// glitches and the time a circuit takes to settle become visible.
+//
+// All nodes start out unknown, except flip-flops and latches, which start out false.
+// SR latches react to their inputs like gates; flip-flops are never clocked and
+// keep their value. Every node is evaluated once at time 0, and inputs change at
+// the given times.
+// Cycles are allowed; a circuit that never settles is stopped at options.Until or
+// after a fixed number of changes.
+func (c *Circuit) SimulateTiming(ctx context.Context, inputs []*TimedInputValue, options TimingOptions) (*TimingResult, error) {
+	if err := c.checkStructure(); err != nil {
+		return nil, err
+	}
+	graph := c.newEvaluationGraph()
+
+	delays, err := c.nodeDelays(options)
+	if err != nil {
+		return nil, err
+	}
+	sim := newTimingSimulator(c, graph, delays)
+	for _, node := range c.Nodes {
+		if isStateNode(node) {
+			sim.values[node.GetID()] = LogicFalse
+		} else {
+			sim.values[node.GetID()] = LogicUnknown
+		}
+	}
+
+	for _, input := range inputs {
+		if input.Time < 0 {
+			return nil, fmt.Errorf("input change scheduled at negative time %d", input.Time)
+		}
+		nodeID, err := graph.inputNodeID(&InputNodeValue{NodeID: input.NodeID, Title: input.Title, Value: input.Value})
+		if err != nil {
+			return nil, err
+		}
+		sim.schedule(int64(input.Time), nodeID, (&InputNodeValue{Value: input.Value}).state())
+	}
+	for _, node := range c.Nodes {
+		switch node.(type) {
+		case *InputNode, *DFlipFlopNode:
+			continue
+		}
+		if err := sim.evaluate(0, node); err != nil {
+			return nil, err
+		}
+	}
+
+	until := int64(math.MaxInt32)
+	if options.Until != nil {
+		until = int64(*options.Until)
+	}
+	result := &TimingResult{Changes: []*ValueChange{}}
+	result.EndTime, result.Truncated, err = sim.run(ctx, until, func(change *ValueChange) {
+		result.Changes = append(result.Changes, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := c.collectOutputs(sim.values)
+	if err != nil {
+		return nil, err
+	}
+	evaluation := successfulResult(outputs)
+	result.Outputs = evaluation.Outputs
+	result.NamedOutputs = evaluation.NamedOutputs
+	return result, nil
+}
+
+// timingSimulator propagates scheduled value changes through a circuit, each node
+// reacting to its inputs after its delay.
+type timingSimulator struct {
+	circuit *Circuit
+	graph   *evaluationGraph
+	delays  map[string]int32
+	// outgoingEdges maps each node to the nodes it feeds, including SR latches.
+	outgoingEdges map[string][]string
+	values        map[string]LogicValue
+	queue         timingQueue
+	scheduled     int
+}
+
+// newTimingSimulator creates a simulator with no values; callers set the initial
+// value of every node before scheduling events.
+func newTimingSimulator(c *Circuit, graph *evaluationGraph, delays map[string]int32) *timingSimulator {
+	sim := &timingSimulator{
+		circuit:       c,
+		graph:         graph,
+		delays:        delays,
+		outgoingEdges: make(map[string][]string),
+		values:        make(map[string]LogicValue, len(c.Nodes)),
+	}
+	for _, node := range c.Nodes {
+		for _, source := range graph.incomingEdges[node.GetID()] {
+			sim.outgoingEdges[source] = append(sim.outgoingEdges[source], node.GetID())
+		}
+		if _, ok := node.(*SRLatchNode); ok {
+			for _, source := range graph.stateEdges[node.GetID()] {
+				sim.outgoingEdges[source] = append(sim.outgoingEdges[source], node.GetID())
+			}
+		}
+	}
+	return sim
+}
+
+// schedule sets the value of a node at the given time.
+func (s *timingSimulator) schedule(at int64, nodeID string, value LogicValue) {
+	heap.Push(&s.queue, timingEvent{time: at, seq: s.scheduled, nodeID: nodeID, value: value})
+	s.scheduled++
+}
+
+// evaluate computes the value of node from the current values of its inputs and
+// schedules it after the node's delay.
+func (s *timingSimulator) evaluate(now int64, node Node) error {
+	nodeID := node.GetID()
+	if _, ok := node.(*SRLatchNode); ok {
+		var set, reset []LogicValue
+		for i, source := range s.graph.stateEdges[nodeID] {
+			if s.graph.statePorts[nodeID][i] == PortSet {
+				set = append(set, s.values[source])
+			} else {
+				reset = append(reset, s.values[source])
+			}
+		}
+		next := nextLatchValue(s.values[nodeID], gateInput(resolveBus(set)), gateInput(resolveBus(reset)))
+		s.schedule(now+int64(s.delays[nodeID]), nodeID, next)
+		return nil
+	}
+
+	sources := s.graph.incomingEdges[nodeID]
+	inputValues := make([]LogicValue, len(sources))
+	for i, source := range sources {
+		inputValues[i] = s.values[source]
+	}
+	value, err := s.circuit.evaluateNode(node, inputValues, s.graph.incomingPorts[nodeID])
+	if err != nil {
+		return fmt.Errorf("failed to evaluate node %s: %w", nodeID, err)
+	}
+	s.schedule(now+int64(s.delays[nodeID]), nodeID, value)
+	return nil
+}
+
+// run processes events up to and including time until, passing every value change
+// to record. It returns the time of the last processed event and whether events
+// were left pending.
+func (s *timingSimulator) run(ctx context.Context, until int64, record func(*ValueChange)) (int32, bool, error) {
+	var endTime int32
+	changes := 0
+	for s.queue.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return 0, false, err
+		}
+		now := s.queue[0].time
+		if now > until || now > math.MaxInt32 || changes >= maxTimingChanges {
+			return endTime, true, nil
+		}
+		endTime = int32(now)
+
+		// Apply every event due now, then re-evaluate the nodes they feed.
+		var affected []string
+		isAffected := make(map[string]bool)
+		for s.queue.Len() > 0 && s.queue[0].time == now {
+			event := heap.Pop(&s.queue).(timingEvent)
+			if s.values[event.nodeID] == event.value {
+				continue
+			}
+			s.values[event.nodeID] = event.value
+			changes++
+			record(&ValueChange{
+				Time:   int32(now),
+				NodeID: event.nodeID,
+				Title:  NodeTitle(s.graph.nodeMap[event.nodeID]),
+				Value:  event.value.Bool(),
+				State:  event.value,
+			})
+
+			for _, target := range s.outgoingEdges[event.nodeID] {
+				if !isAffected[target] {
+					isAffected[target] = true
+					affected = append(affected, target)
+				}
+			}
+		}
+		for _, nodeID := range affected {
+			if err := s.evaluate(now, s.graph.nodeMap[nodeID]); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+	return endTime, false, nil
+}
+
+// nodeDelays returns the propagation delay of every node.
+func (c *Circuit) nodeDelays(options TimingOptions) (map[string]int32, error) {
+	typeDelays := map[NodeType]int32{
+		NodeTypeAnd:      DefaultGateDelay,
+		NodeTypeOr:       DefaultGateDelay,
+		NodeTypeNot:      DefaultGateDelay,
+		NodeTypeTriState: DefaultGateDelay,
+		NodeTypeSRLatch:  DefaultGateDelay,
+	}
+	for _, typeDelay := range options.TypeDelays {
+		if typeDelay.Delay < 0 {
+			return nil, fmt.Errorf("delay of %s nodes cannot be negative", typeDelay.Type)
+		}
+		typeDelays[typeDelay.Type] = typeDelay.Delay
+	}
+
+	delays := make(map[string]int32, len(c.Nodes))
+	for _, node := range c.Nodes {
+		delays[node.GetID()] = typeDelays[NodeTypeOf(node)]
+	}
+	for _, nodeDelay := range options.NodeDelays {
+		if _, exists := delays[nodeDelay.NodeID]; !exists {
+			return nil, fmt.Errorf("delay given for non-existent node: %s", nodeDelay.NodeID)
+		}
+		if nodeDelay.Delay < 0 {
+			return nil, fmt.Errorf("delay of node %s cannot be negative", nodeDelay.NodeID)
+		}
+		delays[nodeDelay.NodeID] = nodeDelay.Delay
+	}
+	return delays, nil
+}
+
+// timingEvent sets the output of a node to value at time. seq keeps events due
+// at the same time in the order they were scheduled.
+type timingEvent struct {
+	time   int64
+	seq    int
+	nodeID string
+	value  LogicValue
+}
+
+// timingQueue is a min-heap of events ordered by time and then scheduling order.
+type timingQueue []timingEvent
+
+func (q timingQueue) Len() int { return len(q) }
+func (q timingQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time < q[j].time
+	}
+	return q[i].seq < q[j].seq
+}
+func (q timingQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *timingQueue) Push(x interface{}) { *q = append(*q, x.(timingEvent)) }
+func (q *timingQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CheckUniqueTitles reports an error if two InputNodes, or two OutputNodes, of the
+// circuit share a non-empty title. Operations that address every input and output
+// by title, such as equivalence checking, require unique titles.
+func (c *Circuit) CheckUniqueTitles() error {
+	inputs := make(map[string]string)
+	outputs := make(map[string]string)
+	for _, node := range c.Nodes {
+		var title, kind string
+		var seen map[string]string
+		switch n := node.(type) {
+		case *InputNode:
+			title, kind, seen = n.Title, "input", inputs
+		case *OutputNode:
+			title, kind, seen = n.Title, "output", outputs
+		default:
+			continue
+		}
+		if title == "" {
+			continue
+		}
+		if other, exists := seen[title]; exists {
+			return fmt.Errorf("duplicate %s title '%s' used by nodes %s and %s", kind, title, other, node.GetID())
+		}
+		seen[title] = node.GetID()
+	}
+	return nil
+}
+
+// InputsByTitle maps the title of each titled InputNode to its ID. A title shared
+// by several InputNodes maps to the empty string, as it does not identify a node.
+func (c *Circuit) InputsByTitle() map[string]string {
+	inputs := make(map[string]string)
+	for _, node := range c.Nodes {
+		if input, ok := node.(*InputNode); ok && input.Title != "" {
+			if _, exists := inputs[input.Title]; exists {
+				inputs[input.Title] = ""
+			} else {
+				inputs[input.Title] = input.ID
+			}
+		}
+	}
+	return inputs
+}
+
+// NamedOutputs maps the title of each titled OutputNode to its value. Titles shared
+// by several OutputNodes are left out. It is
+// presented as a JSON object whose values are true, false, or null when unknown.
+type NamedOutputs map[string]LogicValue
+
+func (n *NamedOutputs) UnmarshalGQL(v interface{}) error {
+	values, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("NamedOutputs must be an object")
+	}
+
+	*n = make(NamedOutputs, len(values))
+	for title, value := range values {
+		switch value := value.(type) {
+		case bool:
+			(*n)[title] = LogicFromBool(value)
+		case nil:
+			(*n)[title] = LogicUnknown
+		default:
+			return fmt.Errorf("value of output '%s' must be a boolean or null", title)
+		}
+	}
+	return nil
+}
+
+func (n NamedOutputs) MarshalGQL(w io.Writer) {
+	values := make(map[string]*bool, len(n))
+	for title, value := range n {
+		values[title] = value.Bool()
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		fmt.Fprint(w, "null")
+		return
+	}
+	w.Write(data)
+}
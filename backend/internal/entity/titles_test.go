@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDuplicateTitlesOnlyFailWhenAddressed(t *testing.T) {
+	ctx := context.Background()
+	// Two inputs and two outputs are titled A and Y.
+	c := buildCircuit("dup", []Node{input("a1", "A"), input("a2", "A"), &AndNode{ID: "g"}, output("y1", "Y"), output("y2", "Y"), output("z", "Z")},
+		"a1>g", "a2>g", "g>y1", "a1>y2", "g>z")
+	validated, err := c.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	value := true
+	result, err := validated.Evaluate(ctx, []*InputNodeValue{{NodeID: "a1", Value: &value}, {NodeID: "a2", Value: &value}})
+	if err != nil {
+		t.Fatalf("Evaluate() by node ID error = %v", err)
+	}
+	if _, ok := result.NamedOutputs["Y"]; ok {
+		t.Error("NamedOutputs includes the shared title Y")
+	}
+	if got := result.NamedOutputs["Z"]; got != LogicTrue {
+		t.Errorf("NamedOutputs[Z] = %v, want TRUE", got)
+	}
+
+	if _, err := validated.Evaluate(ctx, []*InputNodeValue{{Title: "A", Value: &value}}); err == nil {
+		t.Error("Evaluate() by a shared title succeeded")
+	}
+	if _, err := c.EncodeCNF(ctx); err == nil {
+		t.Error("EncodeCNF() succeeded with shared titles")
+	}
+}
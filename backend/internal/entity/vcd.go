@@ -0,0 +1,178 @@
+package entity
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// vcdTimescale is the duration of one input vector in a VCD document.
+const vcdTimescale = "1ns"
+
+// maxVCDVectors caps the input vectors of a VCD document.
+const maxVCDVectors = 100000
+
+// WriteVCD evaluates the circuit for each input vector in turn and writes the value of
+// every node as a Value Change Dump (IEEE 1364), which waveform viewers such as
+// GTKWave can display. Vector i is applied at time i; inputs a vector does not
+// mention keep their value from the previous vector, and start out unknown.
+//
+// Nodes are named by title, or by type and ID if they have none. Each CircuitNode
+// that was flattened into the circuit gets its own scope, named after the circuit it
+// references.
+func (f *FlatCircuit) WriteVCD(ctx context.Context, w io.Writer, vectors [][]*InputNodeValue) error {
+	if len(vectors) > maxVCDVectors {
+		return fmt.Errorf("cannot export more than %d input vectors", maxVCDVectors)
+	}
+
+	var first []*InputNodeValue
+	if len(vectors) > 0 {
+		first = vectors[0]
+	}
+	session, err := f.NewEvaluationSession(ctx, first)
+	if err != nil {
+		return err
+	}
+
+	root := f.vcdScopes()
+	codes := make(map[string]string, len(f.Nodes))
+	for i, node := range f.Nodes {
+		codes[node.GetID()] = vcdCode(i)
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "$comment\n  Circuit %s (%s)\n$end\n", f.Title, f.ID)
+	fmt.Fprintf(out, "$timescale %s $end\n", vcdTimescale)
+	root.write(out, codes, 0)
+	fmt.Fprint(out, "$enddefinitions $end\n")
+
+	fmt.Fprint(out, "#0\n$dumpvars\n")
+	previous := make(map[string]LogicValue, len(f.Nodes))
+	for _, node := range f.Nodes {
+		value := session.values[node.GetID()]
+		previous[node.GetID()] = value
+		fmt.Fprintf(out, "%c%s\n", vcdValue(value), codes[node.GetID()])
+	}
+	fmt.Fprint(out, "$end\n")
+
+	for i := 1; i < len(vectors); i++ {
+		if _, err := session.SetInputs(ctx, vectors[i]); err != nil {
+			return fmt.Errorf("vector %d: %w", i, err)
+		}
+
+		wroteTime := false
+		for _, node := range f.Nodes {
+			value := session.values[node.GetID()]
+			if value == previous[node.GetID()] {
+				continue
+			}
+			if !wroteTime {
+				fmt.Fprintf(out, "#%d\n", i)
+				wroteTime = true
+			}
+			previous[node.GetID()] = value
+			fmt.Fprintf(out, "%c%s\n", vcdValue(value), codes[node.GetID()])
+		}
+	}
+	fmt.Fprintf(out, "#%d\n", len(vectors))
+
+	return out.Flush()
+}
+
+// vcdScope is a module scope of a VCD document.
+type vcdScope struct {
+	name     string
+	nodes    []Node
+	children []*vcdScope
+	byID     map[string]*vcdScope
+}
+
+// vcdScopes arranges the nodes in a tree of scopes following the nesting of CircuitNodes.
+func (f *FlatCircuit) vcdScopes() *vcdScope {
+	root := &vcdScope{name: vcdName(f.Title, "circuit"), byID: make(map[string]*vcdScope)}
+	for _, node := range f.Nodes {
+		scope := root
+		for _, circuitNodeID := range f.Scope(node.GetID()) {
+			child, ok := scope.byID[circuitNodeID]
+			if !ok {
+				title := ""
+				if instance := f.Instance(circuitNodeID); instance != nil {
+					title = instance.Title
+				}
+				child = &vcdScope{name: vcdName(title, "circuit"), byID: make(map[string]*vcdScope)}
+				scope.byID[circuitNodeID] = child
+				scope.children = append(scope.children, child)
+			}
+			scope = child
+		}
+		scope.nodes = append(scope.nodes, node)
+	}
+	return root
+}
+
+// write declares the scope, its signals and its nested scopes.
+func (s *vcdScope) write(out io.Writer, codes map[string]string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(out, "%s$scope module %s $end\n", indent, s.name)
+
+	names := make(map[string]int)
+	unique := func(name string) string {
+		names[name]++
+		if names[name] > 1 {
+			return fmt.Sprintf("%s_%d", name, names[name])
+		}
+		return name
+	}
+	for _, node := range s.nodes {
+		id := node.GetID()
+		local := id[strings.LastIndex(id, "/")+1:]
+		fallback := strings.ToLower(string(NodeTypeOf(node)))
+		if fallback == "" {
+			fallback = "wire"
+		}
+		name := unique(vcdName(NodeTitle(node), fallback+"_"+local))
+		fmt.Fprintf(out, "%s  $var wire 1 %s %s $end\n", indent, codes[id], name)
+	}
+	for _, child := range s.children {
+		child.name = unique(child.name)
+		child.write(out, codes, depth+1)
+	}
+
+	fmt.Fprintf(out, "%s$upscope $end\n", indent)
+}
+
+// vcdName turns a title into a VCD identifier, which cannot contain whitespace.
+func vcdName(title, fallback string) string {
+	if strings.TrimSpace(title) == "" {
+		title = fallback
+	}
+	return strings.Join(strings.Fields(title), "_")
+}
+
+// vcdCode returns the short identifier code of the i-th signal, using the printable
+// ASCII characters '!' to '~'.
+func vcdCode(i int) string {
+	var code []byte
+	for {
+		code = append(code, byte('!'+i%94))
+		i /= 94
+		if i == 0 {
+			return string(code)
+		}
+		i--
+	}
+}
+
+func vcdValue(v LogicValue) byte {
+	switch v {
+	case LogicFalse:
+		return '0'
+	case LogicTrue:
+		return '1'
+	case LogicHighZ:
+		return 'z'
+	}
+	return 'x'
+}
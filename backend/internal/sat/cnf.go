@@ -0,0 +1,51 @@
+// Package sat decides the satisfiability of Boolean formulas in conjunctive normal
+// form with a conflict-driven clause learning (CDCL) solver.
+package sat
+
+import "fmt"
+
+// Lit is a literal in DIMACS notation: variable v as v, its negation as -v.
+// Variables are numbered from 1.
+type Lit int
+
+// Var returns the variable of the literal.
+func (l Lit) Var() int {
+	if l < 0 {
+		return int(-l)
+	}
+	return int(l)
+}
+
+// Not returns the negation of the literal.
+func (l Lit) Not() Lit {
+	return -l
+}
+
+// CNF is a conjunction of clauses, each a disjunction of literals.
+type CNF struct {
+	NumVars int
+	Clauses [][]Lit
+}
+
+// NewVar allocates a variable and returns its positive literal.
+func (f *CNF) NewVar() Lit {
+	f.NumVars++
+	return Lit(f.NumVars)
+}
+
+// Add appends a clause. An empty clause makes the formula unsatisfiable.
+func (f *CNF) Add(clause ...Lit) {
+	f.Clauses = append(f.Clauses, append([]Lit(nil), clause...))
+}
+
+// check reports an error if a clause uses a variable that was not allocated.
+func (f *CNF) check() error {
+	for i, clause := range f.Clauses {
+		for _, lit := range clause {
+			if lit == 0 || lit.Var() > f.NumVars {
+				return fmt.Errorf("clause %d uses literal %d outside variables 1 to %d", i+1, lit, f.NumVars)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package sat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteDIMACS writes f in the DIMACS CNF format read by most SAT solvers, preceded
+// by the given comment lines.
+func (f *CNF) WriteDIMACS(w io.Writer, comments []string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	for _, comment := range comments {
+		// A line break would end the comment early.
+		for _, line := range strings.Split(comment, "\n") {
+			fmt.Fprintf(out, "c %s\n", line)
+		}
+	}
+	fmt.Fprintf(out, "p cnf %d %d\n", f.NumVars, len(f.Clauses))
+	for _, clause := range f.Clauses {
+		for _, lit := range clause {
+			fmt.Fprintf(out, "%d ", lit)
+		}
+		fmt.Fprintln(out, "0")
+	}
+	return out.Flush()
+}
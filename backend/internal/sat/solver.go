@@ -0,0 +1,486 @@
+package sat
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+const (
+	// restartUnit is the number of conflicts of the shortest restart interval; the
+	// intervals follow the Luby sequence.
+	restartUnit = 100
+	// activityDecay is applied to every variable's activity after each conflict.
+	activityDecay = 0.95
+	// ctxCheckInterval is how many conflicts pass between checks for cancellation.
+	ctxCheckInterval = 256
+	// maxConflicts bounds the conflicts Solve spends on a formula before giving up.
+	maxConflicts = 500000
+)
+
+// ErrUndecided is returned by Solve when a formula is neither satisfied nor refuted
+// within the conflict limit.
+var ErrUndecided = errors.New("formula undecided within the conflict limit")
+
+// Solve decides whether f is satisfiable. It returns a model indexed by variable
+// (index 0 is unused) or nil when f is unsatisfiable. Solving stops with ctx.Err()
+// if ctx is cancelled, and with ErrUndecided once the conflict limit is reached.
+func Solve(ctx context.Context, f *CNF) ([]bool, error) {
+	return solveWithin(ctx, f, maxConflicts)
+}
+
+// solveWithin is Solve with a limit of the given number of conflicts.
+func solveWithin(ctx context.Context, f *CNF, limit int) ([]bool, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	s := newSolver(f.NumVars)
+	for _, clause := range f.Clauses {
+		if !s.addClause(clause) {
+			return nil, nil
+		}
+	}
+	satisfiable, err := s.solve(ctx, limit)
+	if err != nil || !satisfiable {
+		return nil, err
+	}
+
+	model := make([]bool, f.NumVars+1)
+	for v := 0; v < f.NumVars; v++ {
+		model[v+1] = s.assigns[v] == valueTrue
+	}
+	return model, nil
+}
+
+// lit is the solver's internal literal: variable v as 2v, its negation as 2v+1,
+// with variables numbered from 0.
+type lit int32
+
+func toLit(l Lit) lit {
+	if l < 0 {
+		return lit(2*(-l-1) + 1)
+	}
+	return lit(2 * (l - 1))
+}
+
+func (l lit) variable() int { return int(l >> 1) }
+func (l lit) negated() bool { return l&1 == 1 }
+func (l lit) not() lit      { return l ^ 1 }
+
+const (
+	valueUnassigned int8 = 0
+	valueTrue       int8 = 1
+	valueFalse      int8 = -1
+)
+
+type clause struct {
+	// lits[0] and lits[1] are watched; in a reason clause, lits[0] is the implied literal.
+	lits   []lit
+	learnt bool
+}
+
+type solver struct {
+	clauses []*clause
+	learnts int
+	// watches holds, for each literal, the clauses watching it.
+	watches [][]*clause
+
+	assigns  []int8
+	level    []int
+	reason   []*clause
+	trail    []lit
+	trailLim []int
+	qhead    int
+
+	activity []float64
+	varInc   float64
+	order    varHeap
+	// polarity saves the last value of each variable for the next decision on it.
+	polarity []bool
+	seen     []bool
+
+	maxLearnts int
+}
+
+func newSolver(vars int) *solver {
+	s := &solver{
+		watches:  make([][]*clause, 2*vars),
+		assigns:  make([]int8, vars),
+		level:    make([]int, vars),
+		reason:   make([]*clause, vars),
+		activity: make([]float64, vars),
+		varInc:   1,
+		polarity: make([]bool, vars),
+		seen:     make([]bool, vars),
+	}
+	s.order = varHeap{activity: s.activity, index: make([]int, vars)}
+	for v := 0; v < vars; v++ {
+		s.order.index[v] = -1
+		s.order.insert(v)
+	}
+	return s
+}
+
+func (s *solver) value(l lit) int8 {
+	value := s.assigns[l.variable()]
+	if l.negated() {
+		return -value
+	}
+	return value
+}
+
+func (s *solver) decisionLevel() int {
+	return len(s.trailLim)
+}
+
+// addClause adds an original clause at decision level 0 and reports false if the
+// formula became unsatisfiable.
+func (s *solver) addClause(original []Lit) bool {
+	lits := make([]lit, 0, len(original))
+	present := make(map[lit]bool, len(original))
+	for _, l := range original {
+		internal := toLit(l)
+		switch {
+		case present[internal.not()] || s.value(internal) == valueTrue:
+			// Tautologies and satisfied clauses constrain nothing.
+			return true
+		case present[internal] || s.value(internal) == valueFalse:
+			continue
+		}
+		present[internal] = true
+		lits = append(lits, internal)
+	}
+
+	switch len(lits) {
+	case 0:
+		return false
+	case 1:
+		s.enqueue(lits[0], nil)
+		return s.propagate() == nil
+	}
+	s.attach(&clause{lits: lits})
+	return true
+}
+
+func (s *solver) attach(c *clause) {
+	s.clauses = append(s.clauses, c)
+	if c.learnt {
+		s.learnts++
+	}
+	s.watches[c.lits[0]] = append(s.watches[c.lits[0]], c)
+	s.watches[c.lits[1]] = append(s.watches[c.lits[1]], c)
+}
+
+func (s *solver) enqueue(l lit, from *clause) {
+	v := l.variable()
+	s.assigns[v] = valueTrue
+	if l.negated() {
+		s.assigns[v] = valueFalse
+	}
+	s.level[v] = s.decisionLevel()
+	s.reason[v] = from
+	s.trail = append(s.trail, l)
+}
+
+// propagate assigns every literal implied by unit clauses and returns a conflicting
+// clause, if any.
+func (s *solver) propagate() *clause {
+	for s.qhead < len(s.trail) {
+		falseLit := s.trail[s.qhead].not()
+		s.qhead++
+
+		watchers := s.watches[falseLit]
+		kept := watchers[:0]
+		for i := 0; i < len(watchers); i++ {
+			c := watchers[i]
+			if c.lits[0] == falseLit {
+				c.lits[0], c.lits[1] = c.lits[1], c.lits[0]
+			}
+			if s.value(c.lits[0]) == valueTrue {
+				kept = append(kept, c)
+				continue
+			}
+
+			// Look for another literal to watch instead of the false one.
+			moved := false
+			for k := 2; k < len(c.lits); k++ {
+				if s.value(c.lits[k]) != valueFalse {
+					c.lits[1], c.lits[k] = c.lits[k], c.lits[1]
+					s.watches[c.lits[1]] = append(s.watches[c.lits[1]], c)
+					moved = true
+					break
+				}
+			}
+			if moved {
+				continue
+			}
+
+			kept = append(kept, c)
+			if s.value(c.lits[0]) == valueFalse {
+				kept = append(kept, watchers[i+1:]...)
+				s.watches[falseLit] = kept
+				return c
+			}
+			s.enqueue(c.lits[0], c)
+		}
+		s.watches[falseLit] = kept
+	}
+	return nil
+}
+
+// analyze derives a clause from a conflict by resolution up to the first unique
+// implication point, and returns it with the level to backjump to. The asserting
+// literal comes first, followed by a literal of the backjump level.
+func (s *solver) analyze(conflict *clause) ([]lit, int) {
+	learnt := []lit{0}
+	pending := 0
+	var p lit = -1
+	index := len(s.trail) - 1
+
+	for c := conflict; ; c = s.reason[p.variable()] {
+		start := 0
+		if p != -1 {
+			// The implied literal of a reason clause is the one being resolved away.
+			start = 1
+		}
+		for _, q := range c.lits[start:] {
+			v := q.variable()
+			if s.seen[v] || s.level[v] == 0 {
+				continue
+			}
+			s.bump(v)
+			s.seen[v] = true
+			if s.level[v] == s.decisionLevel() {
+				pending++
+			} else {
+				learnt = append(learnt, q)
+			}
+		}
+
+		for !s.seen[s.trail[index].variable()] {
+			index--
+		}
+		p = s.trail[index]
+		index--
+		s.seen[p.variable()] = false
+		pending--
+		if pending == 0 {
+			break
+		}
+	}
+	learnt[0] = p.not()
+
+	backjump := 0
+	for i := 1; i < len(learnt); i++ {
+		s.seen[learnt[i].variable()] = false
+		if level := s.level[learnt[i].variable()]; level > backjump {
+			backjump = level
+			learnt[1], learnt[i] = learnt[i], learnt[1]
+		}
+	}
+	return learnt, backjump
+}
+
+func (s *solver) bump(v int) {
+	s.activity[v] += s.varInc
+	if s.activity[v] > 1e100 {
+		for i := range s.activity {
+			s.activity[i] *= 1e-100
+		}
+		s.varInc *= 1e-100
+	}
+	if s.order.contains(v) {
+		s.order.up(s.order.index[v])
+	}
+}
+
+// cancelUntil undoes every assignment above the given decision level.
+func (s *solver) cancelUntil(level int) {
+	if s.decisionLevel() <= level {
+		return
+	}
+	for i := len(s.trail) - 1; i >= s.trailLim[level]; i-- {
+		v := s.trail[i].variable()
+		s.polarity[v] = s.trail[i].negated()
+		s.assigns[v] = valueUnassigned
+		s.reason[v] = nil
+		if !s.order.contains(v) {
+			s.order.insert(v)
+		}
+	}
+	s.trail = s.trail[:s.trailLim[level]]
+	s.trailLim = s.trailLim[:level]
+	s.qhead = len(s.trail)
+}
+
+// decide assigns the most active unassigned variable its saved polarity, and reports
+// false when every variable is assigned.
+func (s *solver) decide() bool {
+	for s.order.len() > 0 {
+		v := s.order.removeMax()
+		if s.assigns[v] != valueUnassigned {
+			continue
+		}
+		s.trailLim = append(s.trailLim, len(s.trail))
+		l := lit(2 * v)
+		if s.polarity[v] {
+			l = l.not()
+		}
+		s.enqueue(l, nil)
+		return true
+	}
+	return false
+}
+
+func (s *solver) solve(ctx context.Context, limit int) (bool, error) {
+	if s.propagate() != nil {
+		return false, nil
+	}
+	s.maxLearnts = len(s.clauses)/3 + 1000
+
+	conflicts := 0
+	for restart := 1; ; restart++ {
+		budget := luby(restart) * restartUnit
+		for budget > 0 {
+			conflict := s.propagate()
+			if conflict == nil {
+				if !s.decide() {
+					return true, nil
+				}
+				continue
+			}
+
+			conflicts++
+			budget--
+			if conflicts > limit {
+				return false, ErrUndecided
+			}
+			if conflicts%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return false, err
+				}
+			}
+			if s.decisionLevel() == 0 {
+				return false, nil
+			}
+
+			learnt, backjump := s.analyze(conflict)
+			s.cancelUntil(backjump)
+			if len(learnt) == 1 {
+				s.enqueue(learnt[0], nil)
+			} else {
+				c := &clause{lits: learnt, learnt: true}
+				s.attach(c)
+				s.enqueue(learnt[0], c)
+			}
+			s.varInc /= activityDecay
+		}
+
+		s.cancelUntil(0)
+		if s.learnts > s.maxLearnts {
+			s.reduceLearnts()
+			s.maxLearnts += s.maxLearnts / 10
+		}
+	}
+}
+
+// reduceLearnts drops the longer half of the learnt clauses. It runs at decision
+// level 0, where no reason is needed any more, and rebuilds the watch lists.
+func (s *solver) reduceLearnts() {
+	var learnts []*clause
+	kept := make([]*clause, 0, len(s.clauses))
+	for _, c := range s.clauses {
+		if c.learnt && len(c.lits) > 2 {
+			learnts = append(learnts, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	sort.SliceStable(learnts, func(i, j int) bool { return len(learnts[i].lits) < len(learnts[j].lits) })
+	kept = append(kept, learnts[:len(learnts)/2]...)
+
+	for v := range s.reason {
+		s.reason[v] = nil
+	}
+	for l := range s.watches {
+		s.watches[l] = s.watches[l][:0]
+	}
+	s.clauses, s.learnts = nil, 0
+	for _, c := range kept {
+		s.attach(c)
+	}
+}
+
+// luby returns the i-th element, from 1, of the Luby sequence 1 1 2 1 1 2 4 ...
+func luby(i int) int {
+	for k := 1; ; k++ {
+		if i == 1<<k-1 {
+			return 1 << (k - 1)
+		}
+		if i < 1<<k-1 {
+			return luby(i - 1<<(k-1) + 1)
+		}
+	}
+}
+
+// varHeap orders variables by activity, most active first.
+type varHeap struct {
+	heap     []int
+	activity []float64
+	// index holds each variable's position in heap, or -1.
+	index []int
+}
+
+func (h *varHeap) len() int            { return len(h.heap) }
+func (h *varHeap) contains(v int) bool { return h.index[v] >= 0 }
+func (h *varHeap) less(i, j int) bool  { return h.activity[h.heap[i]] > h.activity[h.heap[j]] }
+func (h *varHeap) swap(i, j int) {
+	h.heap[i], h.heap[j] = h.heap[j], h.heap[i]
+	h.index[h.heap[i]] = i
+	h.index[h.heap[j]] = j
+}
+
+func (h *varHeap) insert(v int) {
+	h.index[v] = len(h.heap)
+	h.heap = append(h.heap, v)
+	h.up(len(h.heap) - 1)
+}
+
+func (h *varHeap) removeMax() int {
+	v := h.heap[0]
+	last := len(h.heap) - 1
+	h.swap(0, last)
+	h.heap = h.heap[:last]
+	h.index[v] = -1
+	if last > 0 {
+		h.down(0)
+	}
+	return v
+}
+
+func (h *varHeap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) {
+			return
+		}
+		h.swap(i, parent)
+		i = parent
+	}
+}
+
+func (h *varHeap) down(i int) {
+	for {
+		largest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(h.heap) && h.less(child, largest) {
+				largest = child
+			}
+		}
+		if largest == i {
+			return
+		}
+		h.swap(i, largest)
+		i = largest
+	}
+}
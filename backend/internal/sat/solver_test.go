@@ -0,0 +1,170 @@
+package sat
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+// satisfies reports whether model satisfies every clause of f.
+func satisfies(f *CNF, model []bool) bool {
+	for _, clause := range f.Clauses {
+		satisfied := false
+		for _, l := range clause {
+			if model[l.Var()] == (l > 0) {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// bruteForce reports whether any assignment satisfies f.
+func bruteForce(f *CNF) bool {
+	model := make([]bool, f.NumVars+1)
+	for m := 0; m < 1<<f.NumVars; m++ {
+		for v := 1; v <= f.NumVars; v++ {
+			model[v] = m&(1<<(v-1)) != 0
+		}
+		if satisfies(f, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// pigeonhole encodes placing pigeons in holes with no two pigeons sharing a hole,
+// which is unsatisfiable when there are more pigeons than holes.
+func pigeonhole(pigeons, holes int) *CNF {
+	f := &CNF{}
+	in := make([][]Lit, pigeons)
+	for p := range in {
+		in[p] = make([]Lit, holes)
+		for h := range in[p] {
+			in[p][h] = f.NewVar()
+		}
+		f.Add(in[p]...)
+	}
+	for h := 0; h < holes; h++ {
+		for p := 0; p < pigeons; p++ {
+			for q := p + 1; q < pigeons; q++ {
+				f.Add(in[p][h].Not(), in[q][h].Not())
+			}
+		}
+	}
+	return f
+}
+
+func TestSolveTrivial(t *testing.T) {
+	tests := []struct {
+		name        string
+		f           *CNF
+		satisfiable bool
+	}{
+		{"no clauses", &CNF{NumVars: 2}, true},
+		{"no variables", &CNF{}, true},
+		{"empty clause", &CNF{NumVars: 1, Clauses: [][]Lit{{1}, {}}}, false},
+		{"unit clause", &CNF{NumVars: 1, Clauses: [][]Lit{{-1}}}, true},
+		{"contradicting units", &CNF{NumVars: 1, Clauses: [][]Lit{{1}, {-1}}}, false},
+		{"tautology", &CNF{NumVars: 1, Clauses: [][]Lit{{1, -1}}}, true},
+		{"duplicate literals", &CNF{NumVars: 2, Clauses: [][]Lit{{1, 1, 2}, {-1}, {-2, -2}}}, false},
+		{"implication chain", &CNF{NumVars: 3, Clauses: [][]Lit{{1}, {-1, 2}, {-2, 3}}}, true},
+		{"all assignments excluded", &CNF{NumVars: 2, Clauses: [][]Lit{{1, 2}, {1, -2}, {-1, 2}, {-1, -2}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := Solve(context.Background(), tt.f)
+			if err != nil {
+				t.Fatalf("Solve() error = %v", err)
+			}
+			if (model != nil) != tt.satisfiable {
+				t.Fatalf("Solve() satisfiable = %v, want %v", model != nil, tt.satisfiable)
+			}
+			if model != nil && !satisfies(tt.f, model) {
+				t.Fatalf("Solve() model %v does not satisfy the formula", model)
+			}
+		})
+	}
+}
+
+func TestSolveRejectsUnallocatedVariables(t *testing.T) {
+	for _, clause := range [][]Lit{{0}, {2}, {-2}} {
+		f := &CNF{NumVars: 1, Clauses: [][]Lit{clause}}
+		if _, err := Solve(context.Background(), f); err == nil {
+			t.Errorf("Solve() with clause %v: expected an error", clause)
+		}
+	}
+}
+
+func TestSolvePigeonhole(t *testing.T) {
+	for _, tt := range []struct{ pigeons, holes int }{{5, 4}, {7, 6}, {4, 4}} {
+		model, err := Solve(context.Background(), pigeonhole(tt.pigeons, tt.holes))
+		if err != nil {
+			t.Fatalf("Solve() error = %v", err)
+		}
+		if want := tt.pigeons <= tt.holes; (model != nil) != want {
+			t.Errorf("%d pigeons in %d holes: satisfiable = %v, want %v", tt.pigeons, tt.holes, model != nil, want)
+		}
+	}
+}
+
+func TestSolveRandom3SATMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+
+	counts := map[bool]int{}
+	for iteration := 0; iteration < 2000; iteration++ {
+		vars := 3 + r.Intn(10)
+		// Around 4.3 clauses per variable random 3-SAT is as often satisfiable as not.
+		clauses := vars*3 + r.Intn(vars*3)
+		f := &CNF{NumVars: vars}
+		for c := 0; c < clauses; c++ {
+			clause := make([]Lit, 3)
+			for i := range clause {
+				clause[i] = Lit(1 + r.Intn(vars))
+				if r.Intn(2) == 0 {
+					clause[i] = clause[i].Not()
+				}
+			}
+			f.Add(clause...)
+		}
+
+		model, err := Solve(ctx, f)
+		if err != nil {
+			t.Fatalf("Solve() error = %v", err)
+		}
+		if want := bruteForce(f); (model != nil) != want {
+			t.Fatalf("iteration %d: satisfiable = %v, want %v", iteration, model != nil, want)
+		}
+		if model != nil && !satisfies(f, model) {
+			t.Fatalf("iteration %d: model does not satisfy the formula", iteration)
+		}
+		counts[model != nil]++
+	}
+	if counts[true] == 0 || counts[false] == 0 {
+		t.Fatalf("instances were not mixed: %d satisfiable, %d unsatisfiable", counts[true], counts[false])
+	}
+}
+
+func TestSolveCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Cancellation is noticed between conflicts, so the instance must be hard.
+	if _, err := Solve(ctx, pigeonhole(11, 10)); err != context.Canceled {
+		t.Fatalf("error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSolveGivesUpAtConflictLimit(t *testing.T) {
+	if _, err := solveWithin(context.Background(), pigeonhole(7, 6), 10); err != ErrUndecided {
+		t.Fatalf("error = %v, want %v", err, ErrUndecided)
+	}
+	if _, err := solveWithin(context.Background(), pigeonhole(4, 4), 10); err != nil {
+		t.Fatalf("Solve() error = %v", err)
+	}
+}
@@ -1,51 +1,204 @@
 package service
 
-import "backend/internal/entity"
+import (
+	"backend/internal/entity"
+	"context"
+	"io"
+)
 
+// CircuitService exposes circuit operations to the API layer.
+// Every method takes the caller's context so that client disconnects and
+// timeouts cancel the underlying database queries and evaluations.
+//
+// Mutations accept an optional expectedVersion. When it is set and the circuit
+// has been changed since, the mutation fails with *entity.ConflictError.
 type CircuitService interface {
 	// Circuit operations
 	
 	// CreateCircuit creates a new circuit with the given title
-	CreateCircuit(title string) (*entity.Circuit, error)
+	CreateCircuit(ctx context.Context, title string) (*entity.Circuit, error)
 	
 	// GetCircuit retrieves a circuit by ID
-	GetCircuit(id string) (*entity.Circuit, error)
+	GetCircuit(ctx context.Context, id string) (*entity.Circuit, error)
+	
+	// GetCircuitsByIDs retrieves a batch of circuits in a single round trip
+	// Unknown IDs are omitted from the result; ordering is not guaranteed
+	GetCircuitsByIDs(ctx context.Context, ids []string) ([]*entity.Circuit, error)
 	
 	// GetAllCircuits retrieves all circuits in the system
-	GetAllCircuits() ([]*entity.Circuit, error)
+	GetAllCircuits(ctx context.Context) ([]*entity.Circuit, error)
+
+	// Revision operations
+
+	// GetCircuitRevisions lists the recorded revisions of a circuit, newest first
+	GetCircuitRevisions(ctx context.Context, circuitID string) ([]*entity.CircuitRevision, error)
+
+	// GetCircuitAtRevision retrieves the circuit as it was at the given revision
+	GetCircuitAtRevision(ctx context.Context, circuitID string, revision int) (*entity.Circuit, error)
+
+	// RestoreCircuitRevision replaces the live circuit with the given revision
+	// Restoring records a new revision; history is never rewritten
+	RestoreCircuitRevision(ctx context.Context, circuitID string, revision int, expectedVersion *int) (*entity.Circuit, error)
+
+	// History operations
+
+	// Undo reverts the most recent node or edge edit of the circuit
+	Undo(ctx context.Context, circuitID string, expectedVersion *int) (*entity.Circuit, error)
+
+	// Redo re-applies the most recently undone edit of the circuit
+	Redo(ctx context.Context, circuitID string, expectedVersion *int) (*entity.Circuit, error)
 
 	// Node operations
 	
 	// CreateInputNode creates an input node in the specified circuit
 	// title is optional for labeling the input
-	CreateInputNode(circuitID string, title string) (*entity.InputNode, error)
+	CreateInputNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.InputNode, error)
 	
 	// CreateOutputNode creates an output node in the specified circuit
 	// title is optional for labeling the output
-	CreateOutputNode(circuitID string, title string) (*entity.OutputNode, error)
+	CreateOutputNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.OutputNode, error)
 	
 	// CreateAndNode creates an AND logic gate in the specified circuit
-	CreateAndNode(circuitID string) (*entity.AndNode, error)
+	CreateAndNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.AndNode, error)
 	
 	// CreateOrNode creates an OR logic gate in the specified circuit
-	CreateOrNode(circuitID string) (*entity.OrNode, error)
+	CreateOrNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.OrNode, error)
 	
 	// CreateNotNode creates a NOT logic gate in the specified circuit
-	CreateNotNode(circuitID string) (*entity.NotNode, error)
+	CreateNotNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.NotNode, error)
+	
+	// CreateTriStateNode creates a tri-state buffer in the specified circuit
+	CreateTriStateNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.TriStateNode, error)
+	
+	// CreateDFlipFlopNode creates a D flip-flop in the specified circuit
+	// title is optional for labeling the flip-flop's state
+	CreateDFlipFlopNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.DFlipFlopNode, error)
+	
+	// CreateSRLatchNode creates an SR latch in the specified circuit
+	// title is optional for labeling the latch's state
+	CreateSRLatchNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.SRLatchNode, error)
 	
 	// CreateCircuitNode creates a reference to another circuit as a reusable component
 	// referencedCircuitID is the circuit to reference
-	CreateCircuitNode(circuitID string, referencedCircuitID string) (*entity.CircuitNode, error)
+	CreateCircuitNode(ctx context.Context, circuitID string, referencedCircuitID string, expectedVersion *int) (*entity.CircuitNode, error)
 
 	// Edge operations
 	
 	// CreateEdge creates a connection between two nodes in a circuit
-	// sourceNodeID connects to targetNodeID
-	CreateEdge(circuitID string, sourceNodeID string, targetNodeID string) (*entity.Edge, error)
+	// sourceNodeID connects to targetNodeID; targetPort names the target's input
+	// for nodes with distinct inputs (see entity.ValidateTargetPort) and is empty otherwise.
+	// For CircuitNodes, sourcePort and targetPort name an output or input of the
+	// referenced circuit by title
+	CreateEdge(ctx context.Context, circuitID string, sourceNodeID string, targetNodeID string, sourcePort string, targetPort string, expectedVersion *int) (*entity.Edge, error)
+
+	// Subscription operations
+
+	// SubscribeCircuitChanges streams node and edge changes made to the circuit
+	// The channel is closed when ctx is done
+	SubscribeCircuitChanges(ctx context.Context, circuitID string) (<-chan *entity.CircuitChangeEvent, error)
+
+	// LiveEvaluation evaluates the circuit now and again whenever its structure changes
+	// or new inputs arrive through SetLiveInputs. Every result carries the session ID,
+	// generated by the server, that SetLiveInputs takes
+	LiveEvaluation(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue) (<-chan *entity.EvaluationResult, error)
+
+	// SetLiveInputs updates inputs of a running live evaluation; inputs not given keep their value
+	// Only the part of the circuit affected by changed inputs is re-evaluated
+	SetLiveInputs(ctx context.Context, sessionID string, inputs []*entity.InputNodeValue) error
+
+	// Simulation operations
+
+	// StartSimulation starts a clocked simulation of the circuit's current structure
+	// Flip-flops and latches start out false; inputs not given are unknown
+	StartSimulation(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue) (*entity.SimulationStep, error)
+
+	// SetSimulationInputs updates inputs of a simulation without a clock step; inputs not given keep their value
+	SetSimulationInputs(ctx context.Context, simulationID string, inputs []*entity.InputNodeValue) (*entity.SimulationStep, error)
+
+	// StepClock advances a simulation's clock and returns the outputs and state after each cycle
+	StepClock(ctx context.Context, simulationID string, cycles int) ([]*entity.SimulationStep, error)
+
+	// ResetSimulation clears a simulation's flip-flops, latches and cycle count
+	ResetSimulation(ctx context.Context, simulationID string) (*entity.SimulationStep, error)
+
+	// StopSimulation discards a simulation; idle simulations are also discarded automatically
+	StopSimulation(ctx context.Context, simulationID string) error
 
 	// Evaluation operations
 	
 	// EvaluateCircuit computes circuit outputs given input values
 	// Main implementation challenge - requires boolean logic evaluation algorithm
-	EvaluateCircuit(circuit *entity.Circuit, inputs []*entity.InputNodeValue) (*entity.EvaluationResult, error)
+	EvaluateCircuit(ctx context.Context, circuit *entity.Circuit, inputs []*entity.InputNodeValue) (*entity.EvaluationResult, error)
+
+	// EvaluateFixedPoint evaluates a circuit that may contain combinational cycles by iterating
+	// from initial node values (false if not given) for at most maxIterations iterations,
+	// reporting whether the values settle, oscillate, or do not converge
+	EvaluateFixedPoint(ctx context.Context, circuit *entity.Circuit, inputs []*entity.InputNodeValue, initial []*entity.NodeValue, maxIterations int) (*entity.FixedPointResult, error)
+
+	// SimulateTiming runs an event-driven simulation with per-node propagation delays,
+	// returning every value change with its timestamp
+	SimulateTiming(ctx context.Context, circuit *entity.Circuit, inputs []*entity.TimedInputValue, options entity.TimingOptions) (*entity.TimingResult, error)
+
+	// DetectHazards finds static and dynamic hazards of a combinational circuit for
+	// single-input transitions. Nested circuits are flattened first
+	DetectHazards(ctx context.Context, circuit *entity.Circuit) (*entity.HazardReport, error)
+
+	// CheckEquivalence decides whether two combinational circuits compute the same
+	// functions, matching inputs and outputs by title, and returns a counterexample
+	// when they differ. Nested circuits are flattened first
+	CheckEquivalence(ctx context.Context, a *entity.Circuit, b *entity.Circuit) (*entity.EquivalenceResult, error)
+
+	// FindSatisfyingInputs uses a SAT solver to find values of the titled inputs that
+	// give the constrained outputs their values, or proves none exist. Nested circuits
+	// are flattened first
+	FindSatisfyingInputs(ctx context.Context, circuit *entity.Circuit, constraints []*entity.ExpectedOutput) (*entity.SatisfiabilityResult, error)
+
+	// ExportVCD evaluates a circuit for a sequence of input vectors, one per time step,
+	// and writes the value of every node to w as a Value Change Dump
+	ExportVCD(ctx context.Context, circuit *entity.Circuit, vectors [][]*entity.InputNodeValue, w io.Writer) error
+
+	// ExportDIMACS writes a Tseitin encoding of a combinational circuit to w in DIMACS
+	// CNF format, optionally asserting output values. Nested circuits are flattened first
+	ExportDIMACS(ctx context.Context, circuit *entity.Circuit, assertions []*entity.ExpectedOutput, w io.Writer) error
+
+	// RunStimulus parses a CSV or JSON stimulus (format is detected when empty), drives
+	// the circuit with it and compares the outputs with its expected-output columns
+	RunStimulus(ctx context.Context, circuit *entity.Circuit, format entity.StimulusFormat, content string, mode entity.StimulusMode) (*entity.StimulusResult, error)
+
+	// Test suites
+
+	// AddCircuitTest attaches a named test case to a circuit after checking that its
+	// inputs and expected outputs exist in the circuit
+	AddCircuitTest(ctx context.Context, circuitID string, name string, inputs []*entity.InputNodeValue, expected []*entity.ExpectedOutput) (*entity.CircuitTest, error)
+
+	// GetCircuitTests lists the test cases of a circuit, ordered by name
+	GetCircuitTests(ctx context.Context, circuitID string) ([]*entity.CircuitTest, error)
+
+	// DeleteCircuitTest removes a test case from a circuit
+	DeleteCircuitTest(ctx context.Context, circuitID string, testID string) error
+
+	// RunCircuitTests evaluates every test case of a circuit and records the run
+	RunCircuitTests(ctx context.Context, circuitID string) (*entity.CircuitTestRun, error)
+
+	// GetCircuitTestRuns lists at most limit past runs of a circuit's tests, newest first
+	GetCircuitTestRuns(ctx context.Context, circuitID string, limit int) ([]*entity.CircuitTestRun, error)
+
+	// Exercises
+
+	// CreateExercise checks and stores an exercise; a reference circuit must have the
+	// exercise's inputs and outputs and be combinational
+	CreateExercise(ctx context.Context, exercise *entity.Exercise) (*entity.Exercise, error)
+
+	// GetExercise retrieves an exercise by ID
+	GetExercise(ctx context.Context, id string) (*entity.Exercise, error)
+
+	// GetAllExercises retrieves all exercises, oldest first
+	GetAllExercises(ctx context.Context) ([]*entity.Exercise, error)
+
+	// SubmitSolution grades a circuit against an exercise for every combination of
+	// inputs and against its constraints, and records the submission
+	SubmitSolution(ctx context.Context, exerciseID string, circuitID string) (*entity.ExerciseSubmission, error)
+
+	// GetExerciseSubmissions lists at most limit submissions for an exercise, newest first
+	GetExerciseSubmissions(ctx context.Context, exerciseID string, limit int) ([]*entity.ExerciseSubmission, error)
 }
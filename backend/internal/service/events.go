@@ -0,0 +1,132 @@
+package service
+
+import (
+	"backend/internal/entity"
+	"context"
+)
+
+// publish hands events to the hub, if there is one.
+func (s *circuitServiceImpl) publish(ctx context.Context, events ...*entity.CircuitChangeEvent) {
+	if s.hub == nil || len(events) == 0 {
+		return
+	}
+	s.hub.Publish(ctx, events...)
+}
+
+func nodeEvent(kind entity.CircuitChangeKind, circuitID string, version int, node entity.Node) *entity.CircuitChangeEvent {
+	return &entity.CircuitChangeEvent{CircuitID: circuitID, Kind: kind, Version: int32(version), Node: node}
+}
+
+func edgeEvent(kind entity.CircuitChangeKind, circuitID string, version int, edge *entity.Edge) *entity.CircuitChangeEvent {
+	return &entity.CircuitChangeEvent{CircuitID: circuitID, Kind: kind, Version: int32(version), Edge: edge}
+}
+
+// operationEvent describes the effect of applying (undo=false) or reverting (undo=true)
+// a logged operation.
+func operationEvent(op *entity.CircuitOperation, undo bool) *entity.CircuitChangeEvent {
+	switch op.Kind {
+	case entity.OperationAddNode:
+		kind := entity.NodeAdded
+		if undo {
+			kind = entity.NodeRemoved
+		}
+		return nodeEvent(kind, op.CircuitID, int(op.Version), op.Node)
+	case entity.OperationAddEdge:
+		kind := entity.EdgeAdded
+		if undo {
+			kind = entity.EdgeRemoved
+		}
+		return edgeEvent(kind, op.CircuitID, int(op.Version), op.Edge)
+	}
+	return nil
+}
+
+// diffEvents describes the replacement of before by after as individual node and
+// edge events, so subscribers can patch their copy instead of refetching.
+func diffEvents(before, after *entity.Circuit) []*entity.CircuitChangeEvent {
+	var events []*entity.CircuitChangeEvent
+	version := int(after.Version)
+
+	beforeNodes := make(map[string]entity.Node, len(before.Nodes))
+	for _, node := range before.Nodes {
+		beforeNodes[node.GetID()] = node
+	}
+	afterNodes := make(map[string]bool, len(after.Nodes))
+	for _, node := range after.Nodes {
+		afterNodes[node.GetID()] = true
+		if old, ok := beforeNodes[node.GetID()]; !ok {
+			events = append(events, nodeEvent(entity.NodeAdded, after.ID, version, node))
+		} else if !sameNode(old, node) {
+			events = append(events, nodeEvent(entity.NodeUpdated, after.ID, version, node))
+		}
+	}
+
+	beforeEdges := make(map[string]*entity.Edge, len(before.Edges))
+	for _, edge := range before.Edges {
+		beforeEdges[edge.ID] = edge
+	}
+	afterEdges := make(map[string]bool, len(after.Edges))
+	for _, edge := range after.Edges {
+		afterEdges[edge.ID] = true
+		if old, ok := beforeEdges[edge.ID]; !ok {
+			events = append(events, edgeEvent(entity.EdgeAdded, after.ID, version, edge))
+		} else if old.SourceNodeID != edge.SourceNodeID || old.TargetNodeID != edge.TargetNodeID || old.SourcePort != edge.SourcePort || old.TargetPort != edge.TargetPort {
+			events = append(events, edgeEvent(entity.EdgeUpdated, after.ID, version, edge))
+		}
+	}
+
+	// Removals are reported edges first, mirroring how they cascade in storage
+	for _, edge := range before.Edges {
+		if !afterEdges[edge.ID] {
+			events = append(events, edgeEvent(entity.EdgeRemoved, after.ID, version, edge))
+		}
+	}
+	for _, node := range before.Nodes {
+		if !afterNodes[node.GetID()] {
+			events = append(events, nodeEvent(entity.NodeRemoved, after.ID, version, node))
+		}
+	}
+
+	return events
+}
+
+// sameNode reports whether two nodes with the same ID have the same type and attributes.
+func sameNode(a, b entity.Node) bool {
+	switch a := a.(type) {
+	case *entity.InputNode:
+		b, ok := b.(*entity.InputNode)
+		return ok && a.Title == b.Title
+	case *entity.OutputNode:
+		b, ok := b.(*entity.OutputNode)
+		return ok && a.Title == b.Title
+	case *entity.AndNode:
+		_, ok := b.(*entity.AndNode)
+		return ok
+	case *entity.OrNode:
+		_, ok := b.(*entity.OrNode)
+		return ok
+	case *entity.NotNode:
+		_, ok := b.(*entity.NotNode)
+		return ok
+	case *entity.TriStateNode:
+		_, ok := b.(*entity.TriStateNode)
+		return ok
+	case *entity.DFlipFlopNode:
+		b, ok := b.(*entity.DFlipFlopNode)
+		return ok && a.Title == b.Title
+	case *entity.SRLatchNode:
+		b, ok := b.(*entity.SRLatchNode)
+		return ok && a.Title == b.Title
+	case *entity.CircuitNode:
+		b, ok := b.(*entity.CircuitNode)
+		return ok && circuitRefID(a) == circuitRefID(b)
+	}
+	return false
+}
+
+func circuitRefID(n *entity.CircuitNode) string {
+	if n.Circuit == nil {
+		return ""
+	}
+	return n.Circuit.ID
+}
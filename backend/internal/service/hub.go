@@ -0,0 +1,89 @@
+package service
+
+import (
+	"backend/data"
+	"backend/internal/entity"
+	"context"
+	"log"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it.
+const subscriberBuffer = 64
+
+// ChangeHub is an in-process pub/sub hub that fans circuit change events out
+// to every subscriber of that circuit. Events travel through a data.ChangeBus,
+// so subscribers also see changes made by other backend instances on the bus.
+type ChangeHub struct {
+	bus  data.ChangeBus
+	mu   sync.RWMutex
+	subs map[string]map[chan *entity.CircuitChangeEvent]struct{}
+}
+
+func NewChangeHub(bus data.ChangeBus) *ChangeHub {
+	h := &ChangeHub{
+		bus:  bus,
+		subs: make(map[string]map[chan *entity.CircuitChangeEvent]struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// run forwards every event on the bus to the subscribers of its circuit.
+func (h *ChangeHub) run() {
+	for event := range h.bus.Subscribe(context.Background()) {
+		h.deliver(event)
+	}
+}
+
+// Subscribe returns a channel of events for circuitID. The subscription ends and
+// the channel is closed when ctx is done.
+func (h *ChangeHub) Subscribe(ctx context.Context, circuitID string) <-chan *entity.CircuitChangeEvent {
+	ch := make(chan *entity.CircuitChangeEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[circuitID] == nil {
+		h.subs[circuitID] = make(map[chan *entity.CircuitChangeEvent]struct{})
+	}
+	h.subs[circuitID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs[circuitID], ch)
+		if len(h.subs[circuitID]) == 0 {
+			delete(h.subs, circuitID)
+		}
+		h.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Publish sends events to the bus. The change has already been committed, so
+// publishing outlives cancellation of ctx and failures are only logged.
+func (h *ChangeHub) Publish(ctx context.Context, events ...*entity.CircuitChangeEvent) {
+	ctx = context.WithoutCancel(ctx)
+	for _, event := range events {
+		if err := h.bus.Publish(ctx, event); err != nil {
+			log.Printf("warning: failed to publish %s event for circuit %s: %v", event.Kind, event.CircuitID, err)
+		}
+	}
+}
+
+// deliver hands event to the current subscribers of its circuit without blocking.
+func (h *ChangeHub) deliver(event *entity.CircuitChangeEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs[event.CircuitID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("warning: dropping %s event for slow subscriber of circuit %s", event.Kind, event.CircuitID)
+		}
+	}
+}
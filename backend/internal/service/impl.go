@@ -3,21 +3,28 @@ package service
 import (
 	"backend/data"
 	"backend/internal/entity"
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/google/uuid"
 )
 
 type circuitServiceImpl struct {
-	repo data.CircuitRepository
+	repo        data.CircuitRepository
+	hub         *ChangeHub
+	live        *liveSessions
+	simulations *simulations
 }
 
-func NewCircuitService(repo data.CircuitRepository) CircuitService {
-	return &circuitServiceImpl{repo}
+// NewCircuitService creates a CircuitService that publishes every change it makes
+// to hub. hub may be nil when nobody needs change events.
+func NewCircuitService(repo data.CircuitRepository, hub *ChangeHub) CircuitService {
+	return &circuitServiceImpl{repo, hub, newLiveSessions(), newSimulations()}
 }
 
 // Circuit operations
-func (s *circuitServiceImpl) CreateCircuit(title string) (*entity.Circuit, error) {
+func (s *circuitServiceImpl) CreateCircuit(ctx context.Context, title string) (*entity.Circuit, error) {
 	if title == "" {
 		return nil, fmt.Errorf("circuit title cannot be empty")
 	}
@@ -29,19 +36,19 @@ func (s *circuitServiceImpl) CreateCircuit(title string) (*entity.Circuit, error
 		Edges: []*entity.Edge{},
 	}
 
-	if err := s.repo.CreateCircuit(circuit); err != nil {
+	if err := s.repo.CreateCircuit(ctx, circuit); err != nil {
 		return nil, fmt.Errorf("failed to create circuit: %w", err)
 	}
 
 	return circuit, nil
 }
 
-func (s *circuitServiceImpl) GetCircuit(id string) (*entity.Circuit, error) {
+func (s *circuitServiceImpl) GetCircuit(ctx context.Context, id string) (*entity.Circuit, error) {
 	if id == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
 
-	circuit, err := s.repo.GetCircuit(id)
+	circuit, err := s.repo.GetCircuit(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get circuit: %w", err)
 	}
@@ -49,8 +56,17 @@ func (s *circuitServiceImpl) GetCircuit(id string) (*entity.Circuit, error) {
 	return circuit, nil
 }
 
-func (s *circuitServiceImpl) GetAllCircuits() ([]*entity.Circuit, error) {
-	circuits, err := s.repo.GetAllCircuits()
+func (s *circuitServiceImpl) GetCircuitsByIDs(ctx context.Context, ids []string) ([]*entity.Circuit, error) {
+	circuits, err := s.repo.GetCircuitsByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuits: %w", err)
+	}
+
+	return circuits, nil
+}
+
+func (s *circuitServiceImpl) GetAllCircuits(ctx context.Context) ([]*entity.Circuit, error) {
+	circuits, err := s.repo.GetAllCircuits(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all circuits: %w", err)
 	}
@@ -58,8 +74,85 @@ func (s *circuitServiceImpl) GetAllCircuits() ([]*entity.Circuit, error) {
 	return circuits, nil
 }
 
+// Revision operations
+func (s *circuitServiceImpl) GetCircuitRevisions(ctx context.Context, circuitID string) ([]*entity.CircuitRevision, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	revisions, err := s.repo.GetCircuitRevisions(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (s *circuitServiceImpl) GetCircuitAtRevision(ctx context.Context, circuitID string, revision int) (*entity.Circuit, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+	if revision < 1 {
+		return nil, fmt.Errorf("revision must be a positive number")
+	}
+
+	circuit, err := s.repo.GetCircuitRevision(ctx, circuitID, revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit revision: %w", err)
+	}
+
+	return circuit, nil
+}
+
+func (s *circuitServiceImpl) RestoreCircuitRevision(ctx context.Context, circuitID string, revision int, expectedVersion *int) (*entity.Circuit, error) {
+	circuit, err := s.GetCircuitAtRevision(ctx, circuitID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writing the snapshot back records it as the newest revision. The events are
+	// diffed against the state it replaced, read in the same transaction, so
+	// concurrent edits cannot skew them.
+	replaced, err := s.repo.UpdateCircuit(ctx, circuit, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore circuit revision: %w", err)
+	}
+	s.publish(ctx, diffEvents(replaced, circuit)...)
+
+	return circuit, nil
+}
+
+// History operations
+func (s *circuitServiceImpl) Undo(ctx context.Context, circuitID string, expectedVersion *int) (*entity.Circuit, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	op, err := s.repo.UndoOperation(ctx, circuitID, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to undo: %w", err)
+	}
+	s.publish(ctx, operationEvent(op, true))
+
+	return s.GetCircuit(ctx, circuitID)
+}
+
+func (s *circuitServiceImpl) Redo(ctx context.Context, circuitID string, expectedVersion *int) (*entity.Circuit, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	op, err := s.repo.RedoOperation(ctx, circuitID, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redo: %w", err)
+	}
+	s.publish(ctx, operationEvent(op, false))
+
+	return s.GetCircuit(ctx, circuitID)
+}
+
 // Node operations
-func (s *circuitServiceImpl) CreateInputNode(circuitID string, title string) (*entity.InputNode, error) {
+func (s *circuitServiceImpl) CreateInputNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.InputNode, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -69,16 +162,21 @@ func (s *circuitServiceImpl) CreateInputNode(circuitID string, title string) (*e
 		ID:    uuid.New().String(),
 		Title: title,
 	}
+	if err := s.checkTitleAvailable(ctx, circuitID, inputNode); err != nil {
+		return nil, err
+	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddNode(circuitID, inputNode); err != nil {
+	version, err := s.repo.AddNode(ctx, circuitID, inputNode, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new input node: %w", err)
 	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, inputNode))
 
 	return inputNode, nil
 }
 
-func (s *circuitServiceImpl) CreateOutputNode(circuitID string, title string) (*entity.OutputNode, error) {
+func (s *circuitServiceImpl) CreateOutputNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.OutputNode, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -88,16 +186,21 @@ func (s *circuitServiceImpl) CreateOutputNode(circuitID string, title string) (*
 		ID:    uuid.New().String(),
 		Title: title,
 	}
+	if err := s.checkTitleAvailable(ctx, circuitID, outputNode); err != nil {
+		return nil, err
+	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddNode(circuitID, outputNode); err != nil {
+	version, err := s.repo.AddNode(ctx, circuitID, outputNode, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new output node: %w", err)
 	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, outputNode))
 
 	return outputNode, nil
 }
 
-func (s *circuitServiceImpl) CreateAndNode(circuitID string) (*entity.AndNode, error) {
+func (s *circuitServiceImpl) CreateAndNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.AndNode, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -108,14 +211,16 @@ func (s *circuitServiceImpl) CreateAndNode(circuitID string) (*entity.AndNode, e
 	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddNode(circuitID, andNode); err != nil {
+	version, err := s.repo.AddNode(ctx, circuitID, andNode, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new AND node: %w", err)
 	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, andNode))
 
 	return andNode, nil
 }
 
-func (s *circuitServiceImpl) CreateOrNode(circuitID string) (*entity.OrNode, error) {
+func (s *circuitServiceImpl) CreateOrNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.OrNode, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -126,14 +231,16 @@ func (s *circuitServiceImpl) CreateOrNode(circuitID string) (*entity.OrNode, err
 	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddNode(circuitID, orNode); err != nil {
+	version, err := s.repo.AddNode(ctx, circuitID, orNode, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new OR node: %w", err)
 	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, orNode))
 
 	return orNode, nil
 }
 
-func (s *circuitServiceImpl) CreateNotNode(circuitID string) (*entity.NotNode, error) {
+func (s *circuitServiceImpl) CreateNotNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.NotNode, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -144,14 +251,78 @@ func (s *circuitServiceImpl) CreateNotNode(circuitID string) (*entity.NotNode, e
 	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddNode(circuitID, notNode); err != nil {
+	version, err := s.repo.AddNode(ctx, circuitID, notNode, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new NOT node: %w", err)
 	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, notNode))
 
 	return notNode, nil
 }
 
-func (s *circuitServiceImpl) CreateCircuitNode(circuitID string, referencedCircuitID string) (*entity.CircuitNode, error) {
+func (s *circuitServiceImpl) CreateTriStateNode(ctx context.Context, circuitID string, expectedVersion *int) (*entity.TriStateNode, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	// Create the new tri-state buffer
+	triStateNode := &entity.TriStateNode{
+		ID: uuid.New().String(),
+	}
+
+	// Update the circuit in the database
+	version, err := s.repo.AddNode(ctx, circuitID, triStateNode, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update circuit with new tri-state node: %w", err)
+	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, triStateNode))
+
+	return triStateNode, nil
+}
+
+func (s *circuitServiceImpl) CreateDFlipFlopNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.DFlipFlopNode, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	// Create the new D flip-flop
+	flipFlopNode := &entity.DFlipFlopNode{
+		ID:    uuid.New().String(),
+		Title: title,
+	}
+
+	// Update the circuit in the database
+	version, err := s.repo.AddNode(ctx, circuitID, flipFlopNode, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update circuit with new D flip-flop node: %w", err)
+	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, flipFlopNode))
+
+	return flipFlopNode, nil
+}
+
+func (s *circuitServiceImpl) CreateSRLatchNode(ctx context.Context, circuitID string, title string, expectedVersion *int) (*entity.SRLatchNode, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	// Create the new SR latch
+	latchNode := &entity.SRLatchNode{
+		ID:    uuid.New().String(),
+		Title: title,
+	}
+
+	// Update the circuit in the database
+	version, err := s.repo.AddNode(ctx, circuitID, latchNode, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update circuit with new SR latch node: %w", err)
+	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, latchNode))
+
+	return latchNode, nil
+}
+
+func (s *circuitServiceImpl) CreateCircuitNode(ctx context.Context, circuitID string, referencedCircuitID string, expectedVersion *int) (*entity.CircuitNode, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -160,7 +331,7 @@ func (s *circuitServiceImpl) CreateCircuitNode(circuitID string, referencedCircu
 	}
 
 	// Verify the referenced circuit exists
-	referencedCircuit, err := s.repo.GetCircuit(referencedCircuitID)
+	referencedCircuit, err := s.repo.GetCircuit(ctx, referencedCircuitID)
 	if err != nil {
 		return nil, fmt.Errorf("referenced circuit not found: %w", err)
 	}
@@ -172,15 +343,17 @@ func (s *circuitServiceImpl) CreateCircuitNode(circuitID string, referencedCircu
 	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddNode(circuitID, circuitNode); err != nil {
+	version, err := s.repo.AddNode(ctx, circuitID, circuitNode, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new circuit node: %w", err)
 	}
+	s.publish(ctx, nodeEvent(entity.NodeAdded, circuitID, version, circuitNode))
 
 	return circuitNode, nil
 }
 
 // Edge operations
-func (s *circuitServiceImpl) CreateEdge(circuitID string, sourceNodeID string, targetNodeID string) (*entity.Edge, error) {
+func (s *circuitServiceImpl) CreateEdge(ctx context.Context, circuitID string, sourceNodeID string, targetNodeID string, sourcePort string, targetPort string, expectedVersion *int) (*entity.Edge, error) {
 	if circuitID == "" {
 		return nil, fmt.Errorf("circuit ID cannot be empty")
 	}
@@ -195,32 +368,49 @@ func (s *circuitServiceImpl) CreateEdge(circuitID string, sourceNodeID string, t
 	}
 
 	// Get the existing circuit
-	circuit, err := s.repo.GetCircuit(circuitID)
+	circuit, err := s.repo.GetCircuit(ctx, circuitID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get circuit: %w", err)
 	}
 
 	// Verify both nodes exist in the circuit
-	var sourceExists, targetExists bool
+	var sourceNode, targetNode entity.Node
 	for _, node := range circuit.Nodes {
 		if node.GetID() == sourceNodeID {
-			sourceExists = true
+			sourceNode = node
 		}
 		if node.GetID() == targetNodeID {
-			targetExists = true
+			targetNode = node
 		}
 	}
 
-	if !sourceExists {
+	if sourceNode == nil {
 		return nil, fmt.Errorf("source node %s not found in circuit", sourceNodeID)
 	}
-	if !targetExists {
+	if targetNode == nil {
 		return nil, fmt.Errorf("target node %s not found in circuit", targetNodeID)
 	}
+	if err := entity.ValidateSourcePort(sourceNode, sourcePort); err != nil {
+		return nil, err
+	}
+	if err := entity.ValidateTargetPort(targetNode, targetPort); err != nil {
+		return nil, err
+	}
+	if circuitNode, ok := sourceNode.(*entity.CircuitNode); ok {
+		if err := s.checkCircuitPort(ctx, circuitNode, sourcePort, false); err != nil {
+			return nil, err
+		}
+	}
+	if circuitNode, ok := targetNode.(*entity.CircuitNode); ok {
+		if err := s.checkCircuitPort(ctx, circuitNode, targetPort, true); err != nil {
+			return nil, err
+		}
+	}
 
 	// Check if edge already exists
 	for _, edge := range circuit.Edges {
-		if edge.SourceNodeID == sourceNodeID && edge.TargetNodeID == targetNodeID {
+		if edge.SourceNodeID == sourceNodeID && edge.TargetNodeID == targetNodeID &&
+			edge.SourcePort == sourcePort && edge.TargetPort == targetPort {
 			return nil, fmt.Errorf("edge already exists between nodes %s and %s", sourceNodeID, targetNodeID)
 		}
 	}
@@ -230,18 +420,67 @@ func (s *circuitServiceImpl) CreateEdge(circuitID string, sourceNodeID string, t
 		ID:           uuid.New().String(),
 		SourceNodeID: sourceNodeID,
 		TargetNodeID: targetNodeID,
+		SourcePort:   sourcePort,
+		TargetPort:   targetPort,
 	}
 
 	// Update the circuit in the database
-	if err := s.repo.AddEdge(circuitID, newEdge); err != nil {
+	version, err := s.repo.AddEdge(ctx, circuitID, newEdge, expectedVersion)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update circuit with new edge: %w", err)
 	}
+	s.publish(ctx, edgeEvent(entity.EdgeAdded, circuitID, version, newEdge))
 
 	return newEdge, nil
 }
 
+// checkCircuitPort verifies that port names an input (or output) of the circuit
+// referenced by circuitNode. The port may be empty if there is exactly one.
+func (s *circuitServiceImpl) checkCircuitPort(ctx context.Context, circuitNode *entity.CircuitNode, port string, input bool) error {
+	if circuitNode.Circuit == nil || circuitNode.Circuit.ID == "" {
+		return fmt.Errorf("circuit node %s does not reference a circuit", circuitNode.ID)
+	}
+	referenced, err := s.repo.GetCircuit(ctx, circuitNode.Circuit.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get referenced circuit: %w", err)
+	}
+	if _, err := entity.CircuitPort(referenced, port, input); err != nil {
+		return fmt.Errorf("circuit node %s: %w", circuitNode.ID, err)
+	}
+	return nil
+}
+
+// Subscription operations
+func (s *circuitServiceImpl) SubscribeCircuitChanges(ctx context.Context, circuitID string) (<-chan *entity.CircuitChangeEvent, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+	if s.hub == nil {
+		return nil, fmt.Errorf("circuit change events are not enabled")
+	}
+
+	// Verify the circuit exists before subscribing
+	if _, err := s.repo.GetCircuit(ctx, circuitID); err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+
+	return s.hub.Subscribe(ctx, circuitID), nil
+}
+
+// checkTitleAvailable reports an error if adding node would give the circuit two
+// inputs or two outputs with the same title.
+func (s *circuitServiceImpl) checkTitleAvailable(ctx context.Context, circuitID string, node entity.Node) error {
+	circuit, err := s.repo.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return fmt.Errorf("failed to get circuit: %w", err)
+	}
+
+	candidate := &entity.Circuit{ID: circuit.ID, Nodes: append(circuit.Nodes, node)}
+	return candidate.CheckUniqueTitles()
+}
+
 // Evaluation operations
-func (s *circuitServiceImpl) EvaluateCircuit(circuit *entity.Circuit, inputs []*entity.InputNodeValue) (*entity.EvaluationResult, error) {
+func (s *circuitServiceImpl) EvaluateCircuit(ctx context.Context, circuit *entity.Circuit, inputs []*entity.InputNodeValue) (*entity.EvaluationResult, error) {
 	if circuit == nil {
 		return &entity.EvaluationResult{
 			Success: false,
@@ -256,16 +495,25 @@ func (s *circuitServiceImpl) EvaluateCircuit(circuit *entity.Circuit, inputs []*
 		}, fmt.Errorf("inputs cannot be nil")
 	}
 
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return &entity.EvaluationResult{
+			Success: false,
+			Error:   err.Error(),
+		}, err
+	}
+
 	// Validate the circuit structure before evaluation
-	if err := circuit.ValidateCircuit(); err != nil {
+	validated, err := flat.Validate()
+	if err != nil {
 		return &entity.EvaluationResult{
 			Success: false,
 			Error:   fmt.Sprintf("circuit validation failed: %v", err),
 		}, fmt.Errorf("circuit validation failed: %w", err)
 	}
 
-	// Use the evaluation engine to evaluate the circuit
-	result, err := circuit.EvaluateCircuit(inputs)
+	// Use the evaluation engine to evaluate the validated circuit
+	result, err := validated.Evaluate(ctx, inputs)
 	if err != nil {
 		return &entity.EvaluationResult{
 			Success: false,
@@ -275,3 +523,332 @@ func (s *circuitServiceImpl) EvaluateCircuit(circuit *entity.Circuit, inputs []*
 
 	return result, nil
 }
+
+func (s *circuitServiceImpl) EvaluateFixedPoint(ctx context.Context, circuit *entity.Circuit, inputs []*entity.InputNodeValue, initial []*entity.NodeValue, maxIterations int) (*entity.FixedPointResult, error) {
+	if circuit == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	result, err := flat.EvaluateFixedPoint(ctx, inputs, initial, maxIterations)
+	if err != nil {
+		return nil, fmt.Errorf("fixed-point evaluation failed: %w", err)
+	}
+	return result, nil
+}
+
+func (s *circuitServiceImpl) SimulateTiming(ctx context.Context, circuit *entity.Circuit, inputs []*entity.TimedInputValue, options entity.TimingOptions) (*entity.TimingResult, error) {
+	if circuit == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	result, err := flat.SimulateTiming(ctx, inputs, options)
+	if err != nil {
+		return nil, fmt.Errorf("timing simulation failed: %w", err)
+	}
+	return result, nil
+}
+
+func (s *circuitServiceImpl) DetectHazards(ctx context.Context, circuit *entity.Circuit) (*entity.HazardReport, error) {
+	if circuit == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	report, err := flat.DetectHazards(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("hazard detection failed: %w", err)
+	}
+	return report, nil
+}
+
+func (s *circuitServiceImpl) CheckEquivalence(ctx context.Context, a *entity.Circuit, b *entity.Circuit) (*entity.EquivalenceResult, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+
+	flatA, err := a.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	flatB, err := b.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	result, err := entity.CheckEquivalence(ctx, flatA.Circuit, flatB.Circuit)
+	if err != nil {
+		return nil, fmt.Errorf("equivalence check failed: %w", err)
+	}
+	return result, nil
+}
+
+func (s *circuitServiceImpl) FindSatisfyingInputs(ctx context.Context, circuit *entity.Circuit, constraints []*entity.ExpectedOutput) (*entity.SatisfiabilityResult, error) {
+	if circuit == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	result, err := flat.FindSatisfyingInputs(ctx, constraints)
+	if err != nil {
+		return nil, fmt.Errorf("satisfiability check failed: %w", err)
+	}
+	return result, nil
+}
+
+func (s *circuitServiceImpl) ExportVCD(ctx context.Context, circuit *entity.Circuit, vectors [][]*entity.InputNodeValue, w io.Writer) error {
+	if circuit == nil {
+		return fmt.Errorf("circuit cannot be nil")
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return err
+	}
+	if err := flat.WriteVCD(ctx, w, vectors); err != nil {
+		return fmt.Errorf("failed to export VCD: %w", err)
+	}
+	return nil
+}
+
+func (s *circuitServiceImpl) ExportDIMACS(ctx context.Context, circuit *entity.Circuit, assertions []*entity.ExpectedOutput, w io.Writer) error {
+	if circuit == nil {
+		return fmt.Errorf("circuit cannot be nil")
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return err
+	}
+	encoding, err := flat.EncodeCNF(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to encode circuit: %w", err)
+	}
+	if err := encoding.AssertOutputs(assertions); err != nil {
+		return fmt.Errorf("failed to encode circuit: %w", err)
+	}
+	if err := encoding.WriteDIMACS(w); err != nil {
+		return fmt.Errorf("failed to export DIMACS: %w", err)
+	}
+	return nil
+}
+
+func (s *circuitServiceImpl) RunStimulus(ctx context.Context, circuit *entity.Circuit, format entity.StimulusFormat, content string, mode entity.StimulusMode) (*entity.StimulusResult, error) {
+	if circuit == nil {
+		return nil, fmt.Errorf("circuit cannot be nil")
+	}
+	if format == "" {
+		format = entity.DetectStimulusFormat(content)
+	}
+
+	stimulus, err := entity.ParseStimulus(format, content)
+	if err != nil {
+		return nil, err
+	}
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	result, err := flat.RunStimulus(ctx, stimulus, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run stimulus: %w", err)
+	}
+	return result, nil
+}
+
+// Test suite operations
+func (s *circuitServiceImpl) AddCircuitTest(ctx context.Context, circuitID string, name string, inputs []*entity.InputNodeValue, expected []*entity.ExpectedOutput) (*entity.CircuitTest, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	circuit, err := s.repo.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+
+	test := &entity.CircuitTest{
+		ID:        uuid.New().String(),
+		CircuitID: circuitID,
+		Name:      name,
+		Inputs:    inputs,
+		Expected:  expected,
+	}
+	if err := circuit.CheckTest(test); err != nil {
+		return nil, fmt.Errorf("invalid test: %w", err)
+	}
+	if err := s.repo.AddCircuitTest(ctx, test); err != nil {
+		return nil, fmt.Errorf("failed to save test: %w", err)
+	}
+	return test, nil
+}
+
+func (s *circuitServiceImpl) GetCircuitTests(ctx context.Context, circuitID string) ([]*entity.CircuitTest, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+	return s.repo.GetCircuitTests(ctx, circuitID)
+}
+
+func (s *circuitServiceImpl) DeleteCircuitTest(ctx context.Context, circuitID string, testID string) error {
+	if circuitID == "" {
+		return fmt.Errorf("circuit ID cannot be empty")
+	}
+	if testID == "" {
+		return fmt.Errorf("test ID cannot be empty")
+	}
+	return s.repo.DeleteCircuitTest(ctx, circuitID, testID)
+}
+
+func (s *circuitServiceImpl) RunCircuitTests(ctx context.Context, circuitID string) (*entity.CircuitTestRun, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	circuit, err := s.repo.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	tests, err := s.repo.GetCircuitTests(ctx, circuitID)
+	if err != nil {
+		return nil, err
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	run, err := flat.RunTests(ctx, tests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run tests: %w", err)
+	}
+
+	run.ID = uuid.New().String()
+	if err := s.repo.AddCircuitTestRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to record test run: %w", err)
+	}
+	return run, nil
+}
+
+func (s *circuitServiceImpl) GetCircuitTestRuns(ctx context.Context, circuitID string, limit int) ([]*entity.CircuitTestRun, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("limit must be at least 1, got %d", limit)
+	}
+	return s.repo.GetCircuitTestRuns(ctx, circuitID, limit)
+}
+
+// Exercise operations
+func (s *circuitServiceImpl) CreateExercise(ctx context.Context, exercise *entity.Exercise) (*entity.Exercise, error) {
+	if exercise == nil {
+		return nil, fmt.Errorf("exercise cannot be nil")
+	}
+	if err := exercise.Check(); err != nil {
+		return nil, fmt.Errorf("invalid exercise: %w", err)
+	}
+	if exercise.ReferenceCircuitID != "" {
+		// The reference's truth table is stored, so later changes to the reference
+		// do not regrade the exercise.
+		reference, err := s.flattenReference(ctx, exercise)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exercise: %w", err)
+		}
+		if exercise.TruthTable, err = exercise.ReferenceTruthTable(ctx, reference); err != nil {
+			return nil, fmt.Errorf("invalid exercise: %w", err)
+		}
+	}
+	if exercise.AllowedGateTypes == nil {
+		exercise.AllowedGateTypes = []entity.NodeType{}
+	}
+
+	exercise.ID = uuid.New().String()
+	if err := s.repo.CreateExercise(ctx, exercise); err != nil {
+		return nil, fmt.Errorf("failed to save exercise: %w", err)
+	}
+	return exercise, nil
+}
+
+func (s *circuitServiceImpl) GetExercise(ctx context.Context, id string) (*entity.Exercise, error) {
+	if id == "" {
+		return nil, fmt.Errorf("exercise ID cannot be empty")
+	}
+	return s.repo.GetExercise(ctx, id)
+}
+
+func (s *circuitServiceImpl) GetAllExercises(ctx context.Context) ([]*entity.Exercise, error) {
+	return s.repo.GetAllExercises(ctx)
+}
+
+func (s *circuitServiceImpl) SubmitSolution(ctx context.Context, exerciseID string, circuitID string) (*entity.ExerciseSubmission, error) {
+	if exerciseID == "" {
+		return nil, fmt.Errorf("exercise ID cannot be empty")
+	}
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	exercise, err := s.repo.GetExercise(ctx, exerciseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exercise: %w", err)
+	}
+	circuit, err := s.repo.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+
+	submission, err := exercise.Grade(ctx, flat.Circuit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grade solution: %w", err)
+	}
+
+	submission.ID = uuid.New().String()
+	if err := s.repo.AddExerciseSubmission(ctx, submission); err != nil {
+		return nil, fmt.Errorf("failed to record submission: %w", err)
+	}
+	return submission, nil
+}
+
+func (s *circuitServiceImpl) GetExerciseSubmissions(ctx context.Context, exerciseID string, limit int) ([]*entity.ExerciseSubmission, error) {
+	if exerciseID == "" {
+		return nil, fmt.Errorf("exercise ID cannot be empty")
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("limit must be at least 1, got %d", limit)
+	}
+	return s.repo.GetExerciseSubmissions(ctx, exerciseID, limit)
+}
+
+// flattenReference loads and flattens the reference circuit of an exercise and checks
+// that it fits the exercise.
+func (s *circuitServiceImpl) flattenReference(ctx context.Context, exercise *entity.Exercise) (*entity.Circuit, error) {
+	reference, err := s.repo.GetCircuit(ctx, exercise.ReferenceCircuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reference circuit: %w", err)
+	}
+	flat, err := reference.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	if err := exercise.CheckReference(flat.Circuit); err != nil {
+		return nil, err
+	}
+	return flat.Circuit, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"backend/internal/entity"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// liveSessions tracks the input channels of running liveEvaluation subscriptions,
+// keyed by a random session ID the server generates, so that only the subscriber
+// can change a session's inputs.
+type liveSessions struct {
+	mu       sync.Mutex
+	sessions map[string]chan []*entity.InputNodeValue
+}
+
+func newLiveSessions() *liveSessions {
+	return &liveSessions{sessions: make(map[string]chan []*entity.InputNodeValue)}
+}
+
+func (l *liveSessions) open() (string, chan []*entity.InputNodeValue) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sessionID := uuid.New().String()
+	ch := make(chan []*entity.InputNodeValue, 1)
+	l.sessions[sessionID] = ch
+	return sessionID, ch
+}
+
+func (l *liveSessions) close(sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, sessionID)
+}
+
+// send replaces any inputs the session has not picked up yet with inputs.
+func (l *liveSessions) send(sessionID string, inputs []*entity.InputNodeValue) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, exists := l.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("live session %s not found", sessionID)
+	}
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- inputs
+	return nil
+}
+
+func (s *circuitServiceImpl) LiveEvaluation(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue) (<-chan *entity.EvaluationResult, error) {
+	if inputs == nil {
+		return nil, fmt.Errorf("inputs cannot be nil")
+	}
+
+	// Subscribing first guarantees no change slips in between the initial load and the loop
+	changes, err := s.SubscribeCircuitChanges(ctx, circuitID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, inputUpdates := s.live.open()
+
+	results := make(chan *entity.EvaluationResult, 1)
+	go func() {
+		defer close(results)
+		defer s.live.close(sessionID)
+
+		// The evaluator session is rebuilt after structural changes; input updates are
+		// applied incrementally so only the affected part of the circuit is re-evaluated.
+		reload := true
+		var circuit *entity.Circuit
+		var evaluator *entity.EvaluationSession
+		var updates []*entity.InputNodeValue
+		for {
+			if reload {
+				loaded, err := s.repo.GetCircuit(ctx, circuitID)
+				if err != nil {
+					select {
+					case results <- &entity.EvaluationResult{Success: false, Error: fmt.Sprintf("failed to get circuit: %v", err), SessionID: sessionID}:
+					case <-ctx.Done():
+					}
+					return
+				}
+				circuit, evaluator, reload = loaded, nil, false
+			}
+
+			var result *entity.EvaluationResult
+			if evaluator != nil {
+				result, _ = evaluator.SetInputs(ctx, updates)
+			} else if session, err := s.newEvaluationSession(ctx, circuit, inputs); err == nil {
+				evaluator = session
+				result, _ = evaluator.Result()
+			} else {
+				// Evaluation failures are reported in the result; the session stays open
+				result = &entity.EvaluationResult{Success: false, Error: err.Error()}
+			}
+			result.SessionID = sessionID
+
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-changes:
+				if !ok {
+					return
+				}
+				// Coalesce bursts of changes (e.g. a restore) into one re-evaluation
+				drainChanges(changes)
+				reload = true
+			case updates = <-inputUpdates:
+				inputs = mergeInputs(circuit, inputs, updates)
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// newEvaluationSession starts an incremental evaluation of the flattened circuit.
+func (s *circuitServiceImpl) newEvaluationSession(ctx context.Context, circuit *entity.Circuit, inputs []*entity.InputNodeValue) (*entity.EvaluationSession, error) {
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	return flat.NewEvaluationSession(ctx, inputs)
+}
+
+func (s *circuitServiceImpl) SetLiveInputs(ctx context.Context, sessionID string, inputs []*entity.InputNodeValue) error {
+	if sessionID == "" {
+		return fmt.Errorf("session ID cannot be empty")
+	}
+	if inputs == nil {
+		return fmt.Errorf("inputs cannot be nil")
+	}
+	return s.live.send(sessionID, inputs)
+}
+
+// mergeInputs overlays updates on current, keeping inputs that were not updated.
+// Titles are resolved to the circuit's InputNodes first, so an input set by ID and
+// then by title is replaced rather than set twice.
+func mergeInputs(circuit *entity.Circuit, current, updates []*entity.InputNodeValue) []*entity.InputNodeValue {
+	byTitle := circuit.InputsByTitle()
+	merged := make([]*entity.InputNodeValue, 0, len(current)+len(updates))
+	updated := make(map[string]bool, len(updates))
+	for _, input := range updates {
+		updated[inputKey(byTitle, input)] = true
+	}
+	for _, input := range current {
+		if !updated[inputKey(byTitle, input)] {
+			merged = append(merged, input)
+		}
+	}
+	return append(merged, updates...)
+}
+
+// inputKey identifies the InputNode an input value addresses. Titles that do not
+// identify a single InputNode are kept as they are, to fail during evaluation.
+func inputKey(byTitle map[string]string, input *entity.InputNodeValue) string {
+	if input.NodeID != "" {
+		return "id:" + input.NodeID
+	}
+	if nodeID := byTitle[input.Title]; nodeID != "" {
+		return "id:" + nodeID
+	}
+	return "title:" + input.Title
+}
+
+func drainChanges(changes <-chan *entity.CircuitChangeEvent) {
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
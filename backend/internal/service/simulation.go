@@ -0,0 +1,189 @@
+package service
+
+import (
+	"backend/internal/entity"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// simulationIdleTimeout is how long a simulation is kept without being used
+// before it is discarded.
+const simulationIdleTimeout = 30 * time.Minute
+
+// simulationSweepInterval is how often idle simulations are looked for.
+const simulationSweepInterval = time.Minute
+
+// maxSimulations caps the number of simulations held at once.
+const maxSimulations = 1000
+
+// maxClockCycles caps the number of clock steps a single StepClock call may take.
+const maxClockCycles = 10000
+
+// simulations holds the running simulations, keyed by simulation ID.
+type simulations struct {
+	mu       sync.Mutex
+	sessions map[string]*simulationSession
+}
+
+// simulationSession serializes access to a Simulation, which is not safe for concurrent use.
+type simulationSession struct {
+	mu       sync.Mutex
+	sim      *entity.Simulation
+	lastUsed time.Time
+}
+
+func newSimulations() *simulations {
+	s := &simulations{sessions: make(map[string]*simulationSession)}
+	go s.run()
+	return s
+}
+
+// run discards idle simulations periodically.
+func (s *simulations) run() {
+	ticker := time.NewTicker(simulationSweepInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.mu.Lock()
+		s.sweep(now)
+		s.mu.Unlock()
+	}
+}
+
+// sweep discards simulations that have been idle too long. Simulations in use are
+// kept. The caller must hold s.mu.
+func (s *simulations) sweep(now time.Time) {
+	for id, session := range s.sessions {
+		if session.mu.TryLock() {
+			if now.Sub(session.lastUsed) > simulationIdleTimeout {
+				delete(s.sessions, id)
+			}
+			session.mu.Unlock()
+		}
+	}
+}
+
+// add stores sim under a new ID, unless maxSimulations are already running.
+func (s *simulations) add(sim *entity.Simulation) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.sessions) >= maxSimulations {
+		s.sweep(now)
+		if len(s.sessions) >= maxSimulations {
+			return "", fmt.Errorf("too many running simulations (%d); stop one or try again later", maxSimulations)
+		}
+	}
+
+	id := uuid.New().String()
+	s.sessions[id] = &simulationSession{sim: sim, lastUsed: now}
+	return id, nil
+}
+
+// use runs fn with exclusive access to the simulation and stamps the returned steps with its ID.
+func (s *simulations) use(simulationID string, fn func(sim *entity.Simulation) ([]*entity.SimulationStep, error)) ([]*entity.SimulationStep, error) {
+	s.mu.Lock()
+	session, exists := s.sessions[simulationID]
+	s.mu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("simulation %s not found", simulationID)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.lastUsed = time.Now()
+
+	steps, err := fn(session.sim)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		step.SimulationID = simulationID
+	}
+	return steps, nil
+}
+
+func (s *simulations) remove(simulationID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.sessions[simulationID]
+	delete(s.sessions, simulationID)
+	return exists
+}
+
+// Simulation operations
+func (s *circuitServiceImpl) StartSimulation(ctx context.Context, circuitID string, inputs []*entity.InputNodeValue) (*entity.SimulationStep, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("circuit ID cannot be empty")
+	}
+
+	circuit, err := s.repo.GetCircuit(ctx, circuitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit: %w", err)
+	}
+
+	flat, err := circuit.Flatten(ctx, s.repo.GetCircuit)
+	if err != nil {
+		return nil, err
+	}
+	sim, err := flat.NewSimulation(ctx, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start simulation: %w", err)
+	}
+	step, err := sim.Current()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start simulation: %w", err)
+	}
+	if step.SimulationID, err = s.simulations.add(sim); err != nil {
+		return nil, fmt.Errorf("failed to start simulation: %w", err)
+	}
+	return step, nil
+}
+
+func (s *circuitServiceImpl) SetSimulationInputs(ctx context.Context, simulationID string, inputs []*entity.InputNodeValue) (*entity.SimulationStep, error) {
+	steps, err := s.simulations.use(simulationID, func(sim *entity.Simulation) ([]*entity.SimulationStep, error) {
+		step, err := sim.SetInputs(ctx, inputs)
+		return []*entity.SimulationStep{step}, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set simulation inputs: %w", err)
+	}
+	return steps[0], nil
+}
+
+func (s *circuitServiceImpl) StepClock(ctx context.Context, simulationID string, cycles int) ([]*entity.SimulationStep, error) {
+	if cycles > maxClockCycles {
+		return nil, fmt.Errorf("cannot step more than %d cycles at once", maxClockCycles)
+	}
+
+	steps, err := s.simulations.use(simulationID, func(sim *entity.Simulation) ([]*entity.SimulationStep, error) {
+		return sim.StepClock(ctx, cycles)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to step clock: %w", err)
+	}
+	return steps, nil
+}
+
+func (s *circuitServiceImpl) ResetSimulation(ctx context.Context, simulationID string) (*entity.SimulationStep, error) {
+	steps, err := s.simulations.use(simulationID, func(sim *entity.Simulation) ([]*entity.SimulationStep, error) {
+		step, err := sim.Reset(ctx)
+		return []*entity.SimulationStep{step}, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset simulation: %w", err)
+	}
+	return steps[0], nil
+}
+
+func (s *circuitServiceImpl) StopSimulation(ctx context.Context, simulationID string) error {
+	if !s.simulations.remove(simulationID) {
+		return fmt.Errorf("simulation %s not found", simulationID)
+	}
+	return nil
+}